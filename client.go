@@ -1,44 +1,56 @@
 package gremtune
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/traversal"
 )
 
 // Client is a container for the gremtune client.
 type Client struct {
-	conn                   dialer
+	conn                   Transport
 	requests               chan []byte
 	responses              chan []byte
 	results                *sync.Map
 	responseNotifier       *sync.Map // responseNotifier notifies the requester that a response has arrived for the request
 	responseStatusNotifier *sync.Map // responseStatusNotifier notifies the requester that a response has arrived for the request with the code
+	abandoned              *sync.Map // abandoned holds the requests nobody is waiting on anymore, their responses are discarded
+	streams                *sync.Map // streams holds the result sets the responses of their requests are delivered to
+	reconnecting           *sync.RWMutex
+	disconnected           chan struct{} // disconnected is closed once the connection is gone for good
+	disconnectOnce         *sync.Once
+	serializer             Serializer
+	retry                  *RetryPolicy     // retry sends requests that failed for the moment again, if set
+	reconnection           *ReconnectPolicy // reconnection re-dials lost connections, if set
+	retries                *retryCounters
 	sync.RWMutex
 	Errored bool
 }
 
-// NewDialer returns a WebSocket dialer to use when connecting to Gremlin Server
+// NewDialer returns a WebSocket dialer to use when connecting to Gremlin Server. The host is the ws:// or wss:// url
+// of Gremlin Server, its path defaults to /gremlin. An invalid url is reported when dialing.
 func NewDialer(host string, configs ...DialerConfig) (dialer *Ws) {
 	dialer = &Ws{
-		timeout:      5 * time.Second,
-		pingInterval: 60 * time.Second,
-		writingWait:  15 * time.Second,
-		readingWait:  15 * time.Second,
-		connected:    false,
-		quit:         make(chan struct{}),
-		readBufSize:  8192,
-		writeBufSize: 8192,
+		dialerSettings: newDialerSettings(),
+		pingInterval:   60 * time.Second,
+		writingWait:    15 * time.Second,
+		readingWait:    15 * time.Second,
+		connected:      false,
+		quit:           make(chan struct{}),
+		readBufSize:    8192,
+		writeBufSize:   8192,
 	}
 
 	for _, conf := range configs {
 		conf(dialer)
 	}
 
-	dialer.host = host
+	dialer.host, dialer.err = endpoint(host, "ws", "wss")
 	return dialer
 }
 
@@ -48,88 +60,122 @@ func newClient() (c Client) {
 	c.results = &sync.Map{}
 	c.responseNotifier = &sync.Map{}
 	c.responseStatusNotifier = &sync.Map{}
+	c.abandoned = &sync.Map{}
+	c.streams = &sync.Map{}
+	c.reconnecting = &sync.RWMutex{} // reconnecting is held while a lost connection is re-dialed, writes wait on it
+	c.disconnected = make(chan struct{})
+	c.disconnectOnce = &sync.Once{}
+	c.serializer = GraphSONv3
+	c.retries = &retryCounters{}
 	return
 }
 
 // Dial returns a gremtune client for interaction with the Gremlin Server specified in the host IP.
-func Dial(conn dialer, errs chan error) (c Client, err error) {
+func Dial(conn Transport, errs chan error) (c Client, err error) {
 	c = newClient()
 	c.conn = conn
+	if settings, ok := conn.(transportSettings); ok {
+		c.serializer = settings.getSerializer()
+		c.retry = settings.retryPolicy()
+		c.reconnection = settings.reconnectPolicy()
+	}
 
 	// Connects to Gremlin Server
-	err = conn.connect()
+	err = conn.Connect()
 	if err != nil {
 		return
 	}
 
-	quit := conn.(*Ws).quit
+	quit := conn.Done()
 
 	go c.writeWorker(errs, quit)
 	go c.readWorker(errs, quit)
-	go conn.ping(errs)
+	go conn.Ping(errs)
 
 	return
 }
 
-func (c *Client) executeRequest(query string, bindings, rebindings *map[string]string) (resp []Response, err error) {
-	var req request
-	var id string
-	if bindings != nil && rebindings != nil {
-		req, id, err = prepareRequestWithBindings(query, *bindings, *rebindings)
+func (c *Client) executeRequest(ctx context.Context, query string, bindings, rebindings *map[string]string) (resp []Response, err error) {
+	return c.executeEval(ctx, &evalRequest{query: query, bindings: bindings, rebindings: rebindings})
+}
+
+// executeEval submits the eval request and waits for all of its responses, sending it again following the retry
+// policy unless it belongs to a session.
+func (c *Client) executeEval(ctx context.Context, r *evalRequest) (resp []Response, err error) {
+	send := func() (err error) {
+		id, err := c.submitRequest(ctx, r)
+		if err == nil {
+			resp, err = c.retrieveResponse(ctx, id)
+		}
+		return
+	}
+	if r.session != "" {
+		err = send()
 	} else {
-		req, id, err = prepareRequest(query)
+		err = c.withRetry(ctx, r.options != nil && r.options.Idempotent, send)
 	}
 	if err != nil {
-		return
+		err = errors.Wrapf(err, "query: %s", r.query)
 	}
+	return
+}
 
-	msg, err := packageRequest(req)
-	if err != nil {
-		log.Println(err)
-		return
+// withRetry calls send once, or following the retry policy of the client if it has one.
+func (c *Client) withRetry(ctx context.Context, idempotent bool, send func() error) error {
+	if c.retry == nil {
+		return send()
 	}
-	c.responseNotifier.Store(id, make(chan error, 1))
-	c.responseStatusNotifier.Store(id, make(chan int, 1))
-	c.dispatchRequest(msg)
-	resp, err = c.retrieveResponse(id)
+	return c.retry.do(ctx, idempotent, c.retries, send)
+}
+
+// RetryStats returns the number of requests the retry policy of the client sent again.
+func (c *Client) RetryStats() RetryStats {
+	return c.retries.get()
+}
+
+func (c *Client) executeAsync(ctx context.Context, query string, bindings, rebindings *map[string]string, responseChannel chan AsyncResponse) (err error) {
+	r := &evalRequest{query: query, bindings: bindings, rebindings: rebindings}
+	id, err := c.submitRequest(ctx, r)
 	if err != nil {
-		err = errors.Wrapf(err, "query: %s", query)
+		return errors.Wrapf(err, "query: %s", query)
 	}
+	go c.retrieveResponseAsync(ctx, id, responseChannel)
 	return
 }
 
-func (c *Client) executeAsync(query string, bindings, rebindings *map[string]string, responseChannel chan AsyncResponse) (err error) {
-	var req request
-	var id string
-	if bindings != nil && rebindings != nil {
-		req, id, err = prepareRequestWithBindings(query, *bindings, *rebindings)
-	} else {
-		req, id, err = prepareRequest(query)
-	}
-	if err != nil {
+// submitRequest prepares the request and hands it over to the write worker, registering it for its responses.
+func (c *Client) submitRequest(ctx context.Context, r requester) (id string, err error) {
+	if err = r.prepare(); err != nil {
 		return
 	}
+	return r.getID(), c.sendRequest(ctx, r)
+}
 
-	msg, err := packageRequest(req)
+// sendRequest hands the prepared request over to the write worker, registering it for its responses.
+func (c *Client) sendRequest(ctx context.Context, r requester) error {
+	id := r.getID()
+	msg, err := packageRequest(r.getRequest(), c.serializer)
 	if err != nil {
 		log.Println(err)
-		return
+		return err
 	}
 	c.responseNotifier.Store(id, make(chan error, 1))
 	c.responseStatusNotifier.Store(id, make(chan int, 1))
-	c.dispatchRequest(msg)
-	go c.retrieveResponseAsync(id, responseChannel)
-	return
+	if c.isDisconnected() { // Checked once registered so that disconnect either sees the request or is seen here
+		c.abandonRequest(id)
+		return ErrConnectionClosed
+	}
+	return c.dispatchRequestContext(ctx, id, msg)
 }
 
 func (c *Client) authenticate(requestID string) (err error) {
-	auth := c.conn.getAuth()
-	req, err := prepareAuthRequest(requestID, auth.username, auth.password)
+	username, password := c.conn.Auth()
+	req, err := prepareAuthRequest(requestID, username, password)
 	if err != nil {
 		return
 	}
 
-	msg, err := packageRequest(req)
+	msg, err := packageRequest(req, c.serializer)
 	if err != nil {
 		log.Println(err)
 		return
@@ -141,33 +187,107 @@ func (c *Client) authenticate(requestID string) (err error) {
 
 // ExecuteWithBindings formats a raw Gremlin query, sends it to Gremlin Server, and returns the result.
 func (c *Client) ExecuteWithBindings(query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return c.ExecuteWithBindingsContext(context.Background(), query, bindings, rebindings)
+}
+
+// ExecuteWithBindingsContext is like ExecuteWithBindings but gives up waiting for Gremlin Server once ctx is done.
+func (c *Client) ExecuteWithBindingsContext(ctx context.Context, query string, bindings, rebindings map[string]string) (resp []Response, err error) {
 	if c.conn.IsDisposed() {
 		return resp, errors.New("you cannot write on disposed connection")
 	}
-	resp, err = c.executeRequest(query, &bindings, &rebindings)
+	resp, err = c.executeRequest(ctx, query, &bindings, &rebindings)
 	return
 }
 
+// ExecuteWithTypedBindings sends a raw Gremlin query to Gremlin Server with bindings of any type, and returns the
+// result. The bindings are written with their GraphSON or GraphBinary types, so numbers, booleans, dates, UUIDs,
+// slices and maps reach the server as such instead of as strings.
+func (c *Client) ExecuteWithTypedBindings(query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return c.ExecuteWithTypedBindingsContext(context.Background(), query, bindings)
+}
+
+// ExecuteWithTypedBindingsContext is like ExecuteWithTypedBindings but gives up waiting for Gremlin Server once ctx
+// is done.
+func (c *Client) ExecuteWithTypedBindingsContext(ctx context.Context, query string, bindings map[string]interface{}) (resp []Response, err error) {
+	if c.conn.IsDisposed() {
+		return resp, errors.New("you cannot write on disposed connection")
+	}
+	return c.executeEval(ctx, &evalRequest{query: query, typedBindings: bindings})
+}
+
+// ExecuteWithOptions sends a raw Gremlin query to Gremlin Server with the options, and returns the result.
+func (c *Client) ExecuteWithOptions(query string, options RequestOptions) (resp []Response, err error) {
+	return c.ExecuteWithOptionsContext(context.Background(), query, options)
+}
+
+// ExecuteWithOptionsContext is like ExecuteWithOptions but gives up waiting for Gremlin Server once ctx is done.
+func (c *Client) ExecuteWithOptionsContext(ctx context.Context, query string, options RequestOptions) (resp []Response, err error) {
+	if c.conn.IsDisposed() {
+		return resp, errors.New("you cannot write on disposed connection")
+	}
+	return c.executeEval(ctx, &evalRequest{query: query, options: &options})
+}
+
 // Execute formats a raw Gremlin query, sends it to Gremlin Server, and returns the result.
 func (c *Client) Execute(query string) (resp []Response, err error) {
+	return c.ExecuteContext(context.Background(), query)
+}
+
+// ExecuteContext is like Execute but gives up waiting for Gremlin Server once ctx is done.
+func (c *Client) ExecuteContext(ctx context.Context, query string) (resp []Response, err error) {
 	if c.conn.IsDisposed() {
 		return resp, errors.New("you cannot write on disposed connection")
 	}
-	resp, err = c.executeRequest(query, nil, nil)
+	resp, err = c.executeRequest(ctx, query, nil, nil)
 	return
 }
 
 // Execute formats a raw Gremlin query, sends it to Gremlin Server, and the results are streamed to channel provided in method paramater.
 func (c *Client) ExecuteAsync(query string, responseChannel chan AsyncResponse) (err error) {
+	return c.ExecuteAsyncContext(context.Background(), query, responseChannel)
+}
+
+// ExecuteAsyncContext is like ExecuteAsync but stops streaming once ctx is done. The last response sent
+// to the channel then carries the context error in its ErrorMessage.
+func (c *Client) ExecuteAsyncContext(ctx context.Context, query string, responseChannel chan AsyncResponse) (err error) {
 	if c.conn.IsDisposed() {
 		return errors.New("you cannot write on disposed connection")
 	}
-	err = c.executeAsync(query, nil, nil, responseChannel)
+	err = c.executeAsync(ctx, query, nil, nil, responseChannel)
+	return
+}
+
+// Submit sends the traversal to Gremlin Server as bytecode, sparing the server the compilation of a script, and
+// returns the result. The serializer must be GraphSON 2.0 or later, or GraphBinary.
+func (c *Client) Submit(t *traversal.Traversal) (resp []Response, err error) {
+	return c.SubmitContext(context.Background(), t)
+}
+
+// SubmitContext is like Submit but gives up waiting for Gremlin Server once ctx is done.
+func (c *Client) SubmitContext(ctx context.Context, t *traversal.Traversal) (resp []Response, err error) {
+	if c.conn.IsDisposed() {
+		return resp, errors.New("you cannot write on disposed connection")
+	}
+	err = c.withRetry(ctx, false, func() (err error) {
+		id, err := c.submitRequest(ctx, &bytecodeRequest{traversal: t})
+		if err == nil {
+			resp, err = c.retrieveResponse(ctx, id)
+		}
+		return
+	})
+	if err != nil {
+		err = errors.Wrap(err, "bytecode")
+	}
 	return
 }
 
 // ExecuteFileWithBindings takes a file path to a Gremlin script, sends it to Gremlin Server with bindings, and returns the result.
 func (c *Client) ExecuteFileWithBindings(path string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return c.ExecuteFileWithBindingsContext(context.Background(), path, bindings, rebindings)
+}
+
+// ExecuteFileWithBindingsContext is like ExecuteFileWithBindings but gives up waiting for Gremlin Server once ctx is done.
+func (c *Client) ExecuteFileWithBindingsContext(ctx context.Context, path string, bindings, rebindings map[string]string) (resp []Response, err error) {
 	if c.conn.IsDisposed() {
 		return resp, errors.New("you cannot write on disposed connection")
 	}
@@ -177,12 +297,17 @@ func (c *Client) ExecuteFileWithBindings(path string, bindings, rebindings map[s
 		return
 	}
 	query := string(d)
-	resp, err = c.executeRequest(query, &bindings, &rebindings)
+	resp, err = c.executeRequest(ctx, query, &bindings, &rebindings)
 	return
 }
 
 // ExecuteFile takes a file path to a Gremlin script, sends it to Gremlin Server, and returns the result.
 func (c *Client) ExecuteFile(path string) (resp []Response, err error) {
+	return c.ExecuteFileContext(context.Background(), path)
+}
+
+// ExecuteFileContext is like ExecuteFile but gives up waiting for Gremlin Server once ctx is done.
+func (c *Client) ExecuteFileContext(ctx context.Context, path string) (resp []Response, err error) {
 	if c.conn.IsDisposed() {
 		return resp, errors.New("you cannot write on disposed connection")
 	}
@@ -192,13 +317,15 @@ func (c *Client) ExecuteFile(path string) (resp []Response, err error) {
 		return
 	}
 	query := string(d)
-	resp, err = c.executeRequest(query, nil, nil)
+	resp, err = c.executeRequest(ctx, query, nil, nil)
 	return
 }
 
-// Close closes the underlying connection and marks the client as closed.
+// Close closes the underlying connection and marks the client as closed. Requests still waiting on a response
+// fail with ErrConnectionClosed.
 func (c *Client) Close() {
 	if c.conn != nil {
-		c.conn.close()
+		c.conn.Close()
+		c.disconnect()
 	}
 }
@@ -0,0 +1,321 @@
+package gremtune
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// ErrNoHostAvailable is returned by a ClusterPool when every one of its hosts is down.
+var ErrNoHostAvailable = errors.New("no host of the cluster is available")
+
+// Balancing chooses the host of a ClusterPool a request is sent to.
+type Balancing int
+
+const (
+	// RoundRobin takes the hosts in turn.
+	RoundRobin Balancing = iota
+	// LeastInFlight takes the host with the fewest requests waiting on it.
+	LeastInFlight
+)
+
+// defaultProbeInterval is how often hosts that are down are dialed again when ProbeInterval is not set
+const defaultProbeInterval = 5 * time.Second
+
+// ClusterPool spreads requests over several hosts, such as the instances of a Neptune cluster, keeping a Pool of
+// connections to each of them. A host is marked down when dialing it fails or when a connection to it is lost or
+// stops answering pings, idle ones included, and it gets no requests until dialing it again in the background
+// succeeds.
+type ClusterPool struct {
+	Hosts         []string                           // Hosts are the urls of the hosts, passed to Dial
+	Dial          func(host string) (*Client, error) // Dial connects to one of the hosts
+	MaxActive     int                                // MaxActive caps the connections to each host, 0 for no limit
+	IdleTimeout   time.Duration                      // IdleTimeout closes connections idle for longer
+	RetryPolicy   *RetryPolicy                       // RetryPolicy sends requests that failed for the moment again, on any host
+	Balancing     Balancing                          // Balancing chooses the host of every request
+	ProbeInterval time.Duration                      // ProbeInterval is how often hosts that are down are dialed again, 5 seconds by default
+	initOnce      sync.Once
+	mu            sync.Mutex
+	hosts         []*clusterHost
+	next          int
+	quit          chan struct{}
+	closed        bool
+	retries       retryCounters
+}
+
+// clusterHost is a host of a cluster with its connections
+type clusterHost struct {
+	url      string
+	pool     *Pool
+	inFlight int
+	down     bool
+	err      error // err is why the host was marked down
+}
+
+// HostStatus is the state of a host of a ClusterPool.
+type HostStatus struct {
+	Host     string
+	Up       bool
+	InFlight int   // InFlight is the number of connections taken from the host and not returned yet
+	Err      error // Err is why the host is down
+}
+
+// init sets the pools of the hosts up and starts probing the hosts that are down
+func (cp *ClusterPool) init() {
+	cp.initOnce.Do(func() {
+		cp.quit = make(chan struct{})
+		for _, url := range cp.Hosts {
+			url := url
+			cp.hosts = append(cp.hosts, &clusterHost{url: url, pool: &Pool{
+				Dial:        func() (*Client, error) { return cp.Dial(url) },
+				MaxActive:   cp.MaxActive,
+				IdleTimeout: cp.IdleTimeout,
+			}})
+		}
+		go cp.probe()
+	})
+}
+
+// Get returns a connection to one of the hosts that are up.
+func (cp *ClusterPool) Get() (*PooledConnection, error) {
+	return cp.GetContext(context.Background())
+}
+
+// GetContext is like Get but stops waiting for a connection to become available once ctx is done. Hosts which
+// cannot be dialed are marked down and the next one is tried.
+func (cp *ClusterPool) GetContext(ctx context.Context) (*PooledConnection, error) {
+	cp.init()
+	for {
+		h, err := cp.pick()
+		if err != nil {
+			return nil, err
+		}
+		pc, err := h.pool.GetContext(ctx)
+		if err != nil {
+			cp.mu.Lock()
+			h.inFlight--
+			cp.mu.Unlock()
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			cp.markDown(h, err)
+			continue
+		}
+		pc.onClose = func() { cp.done(h, pc.Client) }
+		return pc, nil
+	}
+}
+
+// pick chooses a host that is up following the balancing of the cluster, counting the request in flight on it
+func (cp *ClusterPool) pick() (*clusterHost, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.closed {
+		return nil, ErrConnectionClosed
+	}
+	var picked *clusterHost
+	index := 0
+	for i := range cp.hosts {
+		j := (cp.next + i) % len(cp.hosts)
+		h := cp.hosts[j]
+		if h.down {
+			continue
+		}
+		if picked == nil || (cp.Balancing == LeastInFlight && h.inFlight < picked.inFlight) {
+			picked, index = h, j
+		}
+		if cp.Balancing == RoundRobin {
+			break
+		}
+	}
+	if picked == nil {
+		return nil, ErrNoHostAvailable
+	}
+	cp.next = index + 1 // Ties go to the hosts after this one next time
+	picked.inFlight++
+	return picked, nil
+}
+
+// done counts the returned connection out, marking its host down when the connection was lost or its pings fail
+func (cp *ClusterPool) done(h *clusterHost, c *Client) {
+	cp.mu.Lock()
+	h.inFlight--
+	cp.mu.Unlock()
+	if c.Errored || c.isDisconnected() || (c.conn != nil && !c.conn.IsConnected()) {
+		cp.markDown(h, ErrConnectionLost)
+	}
+}
+
+// markDown stops sending requests to the host until it can be dialed again, closing its idle connections
+func (cp *ClusterPool) markDown(h *clusterHost, err error) {
+	cp.mu.Lock()
+	h.down, h.err = true, err
+	cp.mu.Unlock()
+	h.pool.reset()
+}
+
+// probe checks the hosts every probe interval, all at once so that a slow host does not hold up the others
+func (cp *ClusterPool) probe() {
+	interval := cp.ProbeInterval
+	if interval == 0 {
+		interval = defaultProbeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-cp.quit:
+			return
+		}
+		var wg sync.WaitGroup
+		for _, h := range cp.hosts {
+			wg.Add(1)
+			go func(h *clusterHost) {
+				defer wg.Done()
+				cp.probeHost(h)
+			}(h)
+		}
+		wg.Wait()
+	}
+}
+
+// probeHost marks the host down when one of its idle connections was lost, and up again once dialing it succeeds
+func (cp *ClusterPool) probeHost(h *clusterHost) {
+	cp.mu.Lock()
+	down := h.down
+	cp.mu.Unlock()
+	if !down {
+		if h.pool.idleLost() {
+			cp.markDown(h, ErrConnectionLost)
+		}
+		return
+	}
+	c, err := cp.Dial(h.url)
+	if err != nil {
+		return
+	}
+	c.Close()
+	cp.mu.Lock()
+	h.down, h.err = false, nil
+	cp.mu.Unlock()
+}
+
+// Status returns the state of every host of the cluster.
+func (cp *ClusterPool) Status() []HostStatus {
+	cp.init()
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	status := make([]HostStatus, len(cp.hosts))
+	for i, h := range cp.hosts {
+		status[i] = HostStatus{Host: h.url, Up: !h.down, InFlight: h.inFlight, Err: h.err}
+	}
+	return status
+}
+
+// Close closes the connections to every host and stops probing them.
+func (cp *ClusterPool) Close() {
+	cp.init()
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.closed {
+		return
+	}
+	cp.closed = true
+	close(cp.quit)
+	for _, h := range cp.hosts {
+		h.pool.Close()
+	}
+}
+
+// ExecuteWithBindings formats a raw Gremlin query, sends it to one of the hosts, and returns the result.
+func (cp *ClusterPool) ExecuteWithBindings(query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return cp.ExecuteWithBindingsContext(context.Background(), query, bindings, rebindings)
+}
+
+// ExecuteWithBindingsContext is like ExecuteWithBindings but gives up waiting for a connection or for the host
+// once ctx is done.
+func (cp *ClusterPool) ExecuteWithBindingsContext(ctx context.Context, query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return cp.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithBindingsContext(ctx, query, bindings, rebindings)
+	})
+}
+
+// Execute formats a raw Gremlin query, sends it to one of the hosts, and returns the result.
+func (cp *ClusterPool) Execute(query string) (resp []Response, err error) {
+	return cp.ExecuteContext(context.Background(), query)
+}
+
+// ExecuteContext is like Execute but gives up waiting for a connection or for the host once ctx is done.
+func (cp *ClusterPool) ExecuteContext(ctx context.Context, query string) (resp []Response, err error) {
+	return cp.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteContext(ctx, query)
+	})
+}
+
+// ExecuteWithTypedBindings sends a raw Gremlin query to one of the hosts with bindings of any type, and returns
+// the result.
+func (cp *ClusterPool) ExecuteWithTypedBindings(query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return cp.ExecuteWithTypedBindingsContext(context.Background(), query, bindings)
+}
+
+// ExecuteWithTypedBindingsContext is like ExecuteWithTypedBindings but gives up waiting for a connection or for
+// the host once ctx is done.
+func (cp *ClusterPool) ExecuteWithTypedBindingsContext(ctx context.Context, query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return cp.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithTypedBindingsContext(ctx, query, bindings)
+	})
+}
+
+// ExecuteWithOptions sends a raw Gremlin query to one of the hosts with the options, and returns the result.
+func (cp *ClusterPool) ExecuteWithOptions(query string, options RequestOptions) (resp []Response, err error) {
+	return cp.ExecuteWithOptionsContext(context.Background(), query, options)
+}
+
+// ExecuteWithOptionsContext is like ExecuteWithOptions but gives up waiting for a connection or for the host once
+// ctx is done.
+func (cp *ClusterPool) ExecuteWithOptionsContext(ctx context.Context, query string, options RequestOptions) (resp []Response, err error) {
+	return cp.execute(ctx, options.Idempotent, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithOptionsContext(ctx, query, options)
+	})
+}
+
+// Submit sends the traversal to one of the hosts as bytecode, and returns the result.
+func (cp *ClusterPool) Submit(t *traversal.Traversal) (resp []Response, err error) {
+	return cp.SubmitContext(context.Background(), t)
+}
+
+// SubmitContext is like Submit but gives up waiting for a connection or for the host once ctx is done.
+func (cp *ClusterPool) SubmitContext(ctx context.Context, t *traversal.Traversal) (resp []Response, err error) {
+	return cp.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.SubmitContext(ctx, t)
+	})
+}
+
+// execute sends a request to one of the hosts, and again to the host chosen then following the retry policy of
+// the cluster.
+func (cp *ClusterPool) execute(ctx context.Context, idempotent bool, send func(c *Client) ([]Response, error)) (resp []Response, err error) {
+	attempt := func() error {
+		pc, err := cp.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer pc.Close()
+		resp, err = send(pc.Client)
+		return err
+	}
+	if cp.RetryPolicy == nil {
+		err = attempt()
+	} else {
+		err = cp.RetryPolicy.do(ctx, idempotent, &cp.retries, attempt)
+	}
+	return
+}
+
+// RetryStats returns the number of requests the retry policy of the cluster sent again.
+func (cp *ClusterPool) RetryStats() RetryStats {
+	return cp.retries.get()
+}
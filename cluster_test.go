@@ -0,0 +1,199 @@
+package gremtune
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+// newNamedServer starts a server answering every eval and bytecode request with its name
+func newNamedServer(name string) *gremtunetest.Server {
+	srv := gremtunetest.NewServer()
+	answer := gremtunetest.Success(`["` + name + `"]`)
+	srv.On(gremtunetest.Op("eval"), answer)
+	srv.On(gremtunetest.Op("bytecode"), answer)
+	return srv
+}
+
+func dialCluster(host string) (*Client, error) {
+	c, err := Dial(NewDialer(host), make(chan error, 1))
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// answeredBy returns the name of the server that answered the request, or the error of the request
+func answeredBy(resp []Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	var names []string
+	json.Unmarshal(resp[0].Result.Data, &names)
+	return names[0]
+}
+
+func TestClusterPoolRoundRobin(t *testing.T) {
+	a := newNamedServer("a")
+	defer a.Close()
+	b := newNamedServer("b")
+	defer b.Close()
+
+	cp := &ClusterPool{Hosts: []string{a.URL(), b.URL()}, Dial: dialCluster}
+	defer cp.Close()
+	counts := map[string]int{}
+	for i := 0; i < 4; i++ {
+		counts[answeredBy(cp.Execute("g.V()"))]++
+	}
+	if counts["a"] != 2 || counts["b"] != 2 {
+		t.Errorf("Expected the requests to be spread evenly, got %v", counts)
+	}
+}
+
+func TestClusterPoolLeastInFlight(t *testing.T) {
+	a := newNamedServer("a")
+	defer a.Close()
+	b := newNamedServer("b")
+	defer b.Close()
+
+	cp := &ClusterPool{Hosts: []string{a.URL(), b.URL()}, Dial: dialCluster, Balancing: LeastInFlight}
+	defer cp.Close()
+	held, err := cp.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	first := answeredBy(held.Client.Execute("g.V()"))
+	for i := 0; i < 3; i++ {
+		if host := answeredBy(cp.Execute("g.V()")); host == first {
+			t.Errorf("Expected request %d to go to the host without requests in flight, got %s", i, host)
+		}
+	}
+}
+
+func TestClusterPoolMarksHostsDown(t *testing.T) {
+	a := newNamedServer("a")
+	defer a.Close()
+	b := newNamedServer("b")
+	defer b.Close()
+
+	var bUp int32
+	dial := func(host string) (*Client, error) {
+		if host == b.URL() && atomic.LoadInt32(&bUp) == 0 {
+			return nil, errors.New("connection refused")
+		}
+		return dialCluster(host)
+	}
+	cp := &ClusterPool{Hosts: []string{a.URL(), b.URL()}, Dial: dial, ProbeInterval: 10 * time.Millisecond}
+	defer cp.Close()
+
+	for i := 0; i < 4; i++ {
+		if host := answeredBy(cp.Execute("g.V()")); host != "a" {
+			t.Errorf("Expected request %d to go to the host that is up, got %s", i, host)
+		}
+	}
+	if status := cp.Status(); status[1].Up || status[1].Err == nil {
+		t.Errorf("Expected the host failing to dial to be down, got %+v", status[1])
+	}
+
+	atomic.StoreInt32(&bUp, 1)
+	deadline := time.Now().Add(time.Second)
+	for !cp.Status()[1].Up {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the host to be probed up again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	counts := map[string]int{}
+	for i := 0; i < 4; i++ {
+		counts[answeredBy(cp.Execute("g.V()"))]++
+	}
+	if counts["b"] == 0 {
+		t.Errorf("Expected the host to get requests once up again, got %v", counts)
+	}
+}
+
+func TestClusterPoolNoHostAvailable(t *testing.T) {
+	dial := func(host string) (*Client, error) {
+		return nil, errors.New("connection refused")
+	}
+	cp := &ClusterPool{Hosts: []string{"ws://a", "ws://b"}, Dial: dial}
+	defer cp.Close()
+	if _, err := cp.Execute("g.V()"); !errors.Is(err, ErrNoHostAvailable) {
+		t.Errorf("Expected ErrNoHostAvailable once every host is down, got %v", err)
+	}
+	for _, status := range cp.Status() {
+		if status.Up {
+			t.Errorf("Expected %s to be down", status.Host)
+		}
+	}
+}
+
+func TestClusterPoolProbesIdleConnections(t *testing.T) {
+	a := newNamedServer("a")
+	defer a.Close()
+	b := newNamedServer("b")
+	defer b.Close()
+
+	cp := &ClusterPool{Hosts: []string{a.URL(), b.URL()}, Dial: dialCluster, ProbeInterval: 10 * time.Millisecond}
+	defer cp.Close()
+	counts := map[string]int{}
+	for i := 0; i < 2; i++ {
+		counts[answeredBy(cp.Execute("g.V()"))]++
+	}
+	if counts["b"] != 1 {
+		t.Fatalf("Expected a connection to each host, got %v", counts)
+	}
+
+	b.Close() // The idle connection to b is lost without any request sent on it
+	deadline := time.Now().Add(time.Second)
+	for cp.Status()[1].Up {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the host whose idle connection was lost to be probed down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		if host := answeredBy(cp.Execute("g.V()")); host != "a" {
+			t.Errorf("Expected request %d to go to the host that is up, got %s", i, host)
+		}
+	}
+}
+
+func TestClusterPoolProbesHostsConcurrently(t *testing.T) {
+	b := newNamedServer("b")
+	defer b.Close()
+
+	var up int32
+	dial := func(host string) (*Client, error) {
+		if atomic.LoadInt32(&up) == 0 {
+			return nil, errors.New("connection refused")
+		}
+		if host != b.URL() {
+			time.Sleep(time.Second) // The first host is slow to answer, and still down
+			return nil, errors.New("connection timed out")
+		}
+		return dialCluster(host)
+	}
+	cp := &ClusterPool{Hosts: []string{"ws://slow", b.URL()}, Dial: dial, ProbeInterval: 10 * time.Millisecond}
+	defer cp.Close()
+	if _, err := cp.Execute("g.V()"); !errors.Is(err, ErrNoHostAvailable) {
+		t.Fatalf("Expected ErrNoHostAvailable while every host is down, got %v", err)
+	}
+
+	atomic.StoreInt32(&up, 1)
+	start := time.Now()
+	for !cp.Status()[1].Up {
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatal("Expected the host to be probed up without waiting for the slow one")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if host := answeredBy(cp.Execute("g.V()")); host != "b" {
+		t.Errorf("Expected the request to go to the host that is up again, got %s", host)
+	}
+}
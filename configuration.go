@@ -1,6 +1,11 @@
 package gremtune
 
-import "time"
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
 
 //DialerConfig is the struct for defining configuration for WebSocket dialer
 type DialerConfig func(*Ws)
@@ -48,3 +53,84 @@ func SetBufferSize(readBufferSize int, writeBufferSize int) DialerConfig {
 		c.writeBufSize = writeBufferSize
 	}
 }
+
+//SetSerializer sets the format requests are written and responses read in,
+//Gremlin Server must have a serializer configured for its mime type
+func SetSerializer(serializer Serializer) DialerConfig {
+	return func(c *Ws) {
+		c.serializer = serializer
+	}
+}
+
+//SetReconnectPolicy makes the client re-dial Gremlin Server following the policy
+//when the connection is lost instead of reporting the error
+func SetReconnectPolicy(policy ReconnectPolicy) DialerConfig {
+	return func(c *Ws) {
+		c.reconnect = &policy
+	}
+}
+
+//SetRetryPolicy makes the client send requests that failed for the moment again
+//following the policy
+func SetRetryPolicy(policy RetryPolicy) DialerConfig {
+	return func(c *Ws) {
+		c.retry = &policy
+	}
+}
+
+//SetSigV4Auth signs the websocket handshake with AWS Signature Version 4 for
+//Neptune clusters with IAM database authentication. The handshake is signed
+//again on every reconnect with credentials freshly retrieved from the provider
+func SetSigV4Auth(region string, credentials CredentialsProvider) DialerConfig {
+	return func(c *Ws) {
+		c.signer = &sigV4Signer{
+			region:      region,
+			service:     neptuneService,
+			credentials: credentials,
+			now:         time.Now,
+		}
+	}
+}
+
+//SetTLSConfig sets the TLS configuration used for wss:// connections, e.g. to
+//trust a private CA, present a client certificate or reach Neptune through a
+//local tunnel
+func SetTLSConfig(config *tls.Config) DialerConfig {
+	return func(c *Ws) {
+		c.tlsConfig = config
+	}
+}
+
+//SetProxy sets the function choosing the HTTP proxy for the connection, such
+//as http.ProxyFromEnvironment or http.ProxyURL
+func SetProxy(proxy func(*http.Request) (*url.URL, error)) DialerConfig {
+	return func(c *Ws) {
+		c.proxy = proxy
+	}
+}
+
+//SetHeaders sets extra headers sent with the websocket handshake. A Host header
+//overrides the host sent to the server
+func SetHeaders(header http.Header) DialerConfig {
+	return func(c *Ws) {
+		c.headers = header
+	}
+}
+
+//SetHeaderProvider sets a function called on every dial for headers to send
+//with the websocket handshake, e.g. for short lived tokens or tracing. They are
+//added to, and take precedence over, the ones from SetHeaders
+func SetHeaderProvider(provider func() (http.Header, error)) DialerConfig {
+	return func(c *Ws) {
+		c.headerFunc = provider
+	}
+}
+
+//SetPathDiscovery makes the dialer try the other path of Gremlin Server, the
+//root or /gremlin, when dialing the url it was given fails. The path that
+//worked is used from then on
+func SetPathDiscovery() DialerConfig {
+	return func(c *Ws) {
+		c.discoverPath = true
+	}
+}
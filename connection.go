@@ -1,7 +1,11 @@
 package gremtune
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"sync"
@@ -10,15 +14,27 @@ import (
 	"github.com/pkg/errors"
 )
 
-type dialer interface {
-	connect() error
-	IsConnected() bool
-	IsDisposed() bool
-	write([]byte) error
-	read() (int, []byte, error)
-	close() error
-	getAuth() *auth
-	ping(errs chan error)
+// Transport carries the frames of requests and responses between the client and Gremlin Server. The WebSocket
+// dialer NewDialer returns is one, implement it to plug in another connection, such as an in-memory one for tests
+// or one instrumenting the WebSocket dialer it wraps.
+type Transport interface {
+	Connect() error                             // Connect dials the server, it is called again to reconnect
+	IsConnected() bool                          // IsConnected reports whether the connection is up
+	IsDisposed() bool                           // IsDisposed reports whether the transport was closed
+	Write(msg []byte) error                     // Write sends a request, its mime type header included
+	Read() (msgType int, msg []byte, err error) // Read waits for the next response, failing once the connection is lost or closed
+	Close() error                               // Close closes the connection on purpose, then closes Done
+	Ping(errs chan error)                       // Ping keeps the connection alive until Done is closed
+	Auth() (username, password string)          // Auth returns the credentials answering the challenges of the server
+	Done() <-chan struct{}                      // Done is closed once the transport is closed on purpose, stopping the client
+}
+
+// transportSettings holds the settings of the client that come with the WebSocket and HTTP dialers. Transports
+// embedding *Ws inherit them, others get the GraphSON 3.0 serializer and neither reconnect nor retry.
+type transportSettings interface {
+	reconnectPolicy() *ReconnectPolicy
+	retryPolicy() *RetryPolicy
+	getSerializer() Serializer
 }
 
 /////
@@ -27,20 +43,53 @@ WebSocket Connection
 */
 /////
 
+// dialerSettings holds the settings shared by the WebSocket and the HTTP dialers
+type dialerSettings struct {
+	auth       *auth
+	timeout    time.Duration
+	retry      *RetryPolicy
+	signer     *sigV4Signer
+	tlsConfig  *tls.Config
+	proxy      func(*http.Request) (*url.URL, error)
+	headers    http.Header
+	headerFunc func() (http.Header, error)
+	serializer Serializer
+}
+
+// newDialerSettings returns the default settings of a dialer
+func newDialerSettings() dialerSettings {
+	return dialerSettings{
+		timeout:    5 * time.Second,
+		serializer: GraphSONv3,
+	}
+}
+
+// configuredSettings returns the settings the configs give a dialer, leaving out the ones of the websocket, for
+// dialers sharing the configs of NewDialer
+func configuredSettings(configs []DialerConfig) dialerSettings {
+	ws := &Ws{dialerSettings: newDialerSettings()}
+	for _, conf := range configs {
+		conf(ws)
+	}
+	return ws.dialerSettings
+}
+
 // Ws is the dialer for a WebSocket connection
 type Ws struct {
+	dialerSettings
 	host         string
 	conn         *websocket.Conn
-	auth         *auth
 	disposed     bool
 	connected    bool
 	pingInterval time.Duration
 	writingWait  time.Duration
 	readingWait  time.Duration
-	timeout      time.Duration
 	readBufSize  int
 	writeBufSize int
 	quit         chan struct{}
+	reconnect    *ReconnectPolicy
+	discoverPath bool
+	err          error // err is set when the dialer is misconfigured and returned on connect
 	sync.RWMutex
 }
 
@@ -50,33 +99,151 @@ type auth struct {
 	password string
 }
 
-func (ws *Ws) connect() (err error) {
+// Connect dials Gremlin Server, probing the other path when path discovery is on
+func (ws *Ws) Connect() (err error) {
 	d := websocket.Dialer{
 		WriteBufferSize:  ws.writeBufSize,
 		ReadBufferSize:   ws.readBufSize,
 		HandshakeTimeout: ws.timeout, // Timeout or else we'll hang forever and never fail on bad hosts.
+		TLSClientConfig:  ws.tlsConfig,
+		Proxy:            ws.proxy,
+	}
+	if ws.err != nil {
+		return ws.err
 	}
-	ws.conn, _, err = d.Dial(ws.host, http.Header{})
-	if err != nil {
+
+	conn, err := ws.dial(d, ws.host)
+	if err != nil && ws.discoverPath {
 
 		// As of 3.2.2 the URL has changed.
 		// https://groups.google.com/forum/#!msg/gremlin-users/x4hiHsmTsHM/Xe4GcPtRCAAJ
-		ws.host = ws.host + "/gremlin"
-		ws.conn, _, err = d.Dial(ws.host, http.Header{})
+		alternate := alternatePath(ws.host)
+		var altErr error
+		if conn, altErr = ws.dial(d, alternate); altErr == nil {
+			ws.host = alternate
+			err = nil
+		} else {
+			err = &DialError{URLs: []string{ws.host, alternate}, Errors: []error{err, altErr}}
+		}
 	}
 
 	if err == nil {
+		ws.discoverPath = false // Once found the path sticks, reconnects dial it straight away
+		ws.Lock()
+		if ws.conn != nil { // Reconnecting, let go of the lost connection
+			ws.conn.Close()
+		}
+		ws.conn = conn
 		ws.connected = true
-		ws.conn.SetPongHandler(func(appData string) error {
+		ws.Unlock()
+		conn.SetPongHandler(func(appData string) error {
+			ws.Lock()
 			ws.connected = true
+			ws.Unlock()
 			return nil
 		})
 	}
 	return
 }
 
+// DialError is returned when the dialer probed both the root and the /gremlin path of Gremlin Server and could
+// connect to neither. Errors holds the error of the dial to each of the URLs, in the order they were tried.
+type DialError struct {
+	URLs   []string
+	Errors []error
+}
+
+func (e *DialError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = fmt.Sprintf("%s: %v", e.URLs[i], err)
+	}
+	return "dialing Gremlin Server failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the error of every dial so errors.Is and errors.As match any of them.
+func (e *DialError) Unwrap() []error {
+	return e.Errors
+}
+
+// endpoint validates the url of Gremlin Server, defaulting the path to /gremlin which Gremlin Server serves
+// since 3.2.2 and Neptune always does.
+func endpoint(rawurl, scheme, secureScheme string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid Gremlin Server url %q", rawurl)
+	}
+	if u.Scheme != scheme && u.Scheme != secureScheme {
+		return "", errors.Errorf("invalid Gremlin Server url %q: scheme must be %s or %s", rawurl, scheme, secureScheme)
+	}
+	if u.Host == "" {
+		return "", errors.Errorf("invalid Gremlin Server url %q: missing host", rawurl)
+	}
+	if u.Path == "" {
+		u.Path = "/gremlin"
+	}
+	return u.String(), nil
+}
+
+// alternatePath returns the url with the /gremlin path removed, or added if it does not have it.
+func alternatePath(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	if strings.HasSuffix(u.Path, "/gremlin") {
+		u.Path = strings.TrimSuffix(u.Path, "/gremlin")
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/gremlin"
+	}
+	return u.String()
+}
+
+// dial opens a websocket to the url, signing the handshake when the dialer has SigV4 credentials
+func (ws *Ws) dial(d websocket.Dialer, url string) (conn *websocket.Conn, err error) {
+	header, err := ws.header()
+	if err != nil {
+		return
+	}
+	if ws.signer != nil {
+		if err = ws.signer.signHandshake(url, header); err != nil {
+			return
+		}
+	}
+	conn, _, err = d.Dial(url, header)
+	return
+}
+
+// header gathers the headers of the websocket handshake or of the HTTP request, the ones from the header func win
+// over the static ones
+func (d *dialerSettings) header() (http.Header, error) {
+	header := http.Header{}
+	for name, values := range d.headers {
+		header[name] = append([]string(nil), values...)
+	}
+	if d.headerFunc != nil {
+		extra, err := d.headerFunc()
+		if err != nil {
+			return nil, errors.Wrap(err, "building handshake headers")
+		}
+		for name, values := range extra {
+			header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return header, nil
+}
+
+// getConn returns the current websocket, which changes when the dialer reconnects
+func (ws *Ws) getConn() *websocket.Conn {
+	ws.RLock()
+	defer ws.RUnlock()
+	return ws.conn
+}
+
 // IsConnected returns whether the underlying websocket is connected
 func (ws *Ws) IsConnected() bool {
+	ws.RLock()
+	defer ws.RUnlock()
 	return ws.connected
 }
 
@@ -85,43 +252,69 @@ func (ws *Ws) IsDisposed() bool {
 	return ws.disposed
 }
 
-func (ws *Ws) write(msg []byte) (err error) {
-	err = ws.conn.WriteMessage(2, msg)
+// Write sends a request on the websocket
+func (ws *Ws) Write(msg []byte) (err error) {
+	err = ws.getConn().WriteMessage(2, msg)
 	return
 }
 
-func (ws *Ws) read() (msgType int, msg []byte, err error) {
-	msgType, msg, err = ws.conn.ReadMessage()
+// Read waits for the next message on the websocket
+func (ws *Ws) Read() (msgType int, msg []byte, err error) {
+	msgType, msg, err = ws.getConn().ReadMessage()
 	return
 }
 
-func (ws *Ws) close() (err error) {
+// Close closes the websocket cleanly and stops the workers of the client
+func (ws *Ws) Close() (err error) {
+	conn := ws.getConn()
+	close(ws.quit) // Stop the workers first so the closing connection is not taken for a lost one
 	defer func() {
-		close(ws.quit)
-		ws.conn.Close()
+		conn.Close()
 		ws.disposed = true
 	}()
 
-	err = ws.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")) //Cleanly close the connection with the server
+	// Cleanly close the connection with the server, WriteControl is safe to use alongside the write worker
+	err = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(ws.writingWait))
 	return
 }
 
-func (ws *Ws) getAuth() *auth {
+// Auth returns the credentials of the dialer, it panics when it has none
+func (ws *Ws) Auth() (username, password string) {
 	if ws.auth == nil {
 		panic("You must create a Secure Dialer for authenticate with the server")
 	}
-	return ws.auth
+	return ws.auth.username, ws.auth.password
 }
 
-func (ws *Ws) ping(errs chan error) {
+func (ws *Ws) reconnectPolicy() *ReconnectPolicy {
+	return ws.reconnect
+}
+
+func (d *dialerSettings) retryPolicy() *RetryPolicy {
+	return d.retry
+}
+
+func (d *dialerSettings) getSerializer() Serializer {
+	return d.serializer
+}
+
+// Done returns the channel closed once the connection is closed on purpose, which stops the workers
+func (ws *Ws) Done() <-chan struct{} {
+	return ws.quit
+}
+
+// Ping pings the server every ping interval to keep the websocket alive and to notice it is lost
+func (ws *Ws) Ping(errs chan error) {
 	ticker := time.NewTicker(ws.pingInterval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
 			connected := true
-			if err := ws.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(ws.writingWait)); err != nil {
-				errs <- err
+			if err := ws.getConn().WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(ws.writingWait)); err != nil {
+				if ws.reconnect == nil { // Otherwise the read worker notices the lost connection and reconnects
+					errs <- err
+				}
 				connected = false
 			}
 			ws.Lock()
@@ -134,19 +327,20 @@ func (ws *Ws) ping(errs chan error) {
 	}
 }
 
-func (c *Client) writeWorker(errs chan error, quit chan struct{}) { // writeWorker works on a loop and dispatches messages as soon as it receives them
+func (c *Client) writeWorker(errs chan error, quit <-chan struct{}) { // writeWorker works on a loop and dispatches messages as soon as it receives them
 	for {
 		select {
 		case msg := <-c.requests:
+			c.reconnecting.RLock() // Requests wait while a lost connection is re-dialed
 			c.Lock()
-			err := c.conn.write(msg)
-			if err != nil {
+			err := c.conn.Write(msg)
+			if err != nil && !c.canReconnect(quit) { // Otherwise the read worker notices the lost connection and reconnects
+				c.disconnect()
 				errs <- err
 				c.Errored = true
-				c.Unlock()
-				break
 			}
 			c.Unlock()
+			c.reconnecting.RUnlock()
 
 		case <-quit:
 			return
@@ -154,16 +348,34 @@ func (c *Client) writeWorker(errs chan error, quit chan struct{}) { // writeWork
 	}
 }
 
-func (c *Client) readWorker(errs chan error, quit chan struct{}) { // readWorker works on a loop and sorts messages as soon as it receives them
+func (c *Client) readWorker(errs chan error, quit <-chan struct{}) { // readWorker works on a loop and sorts messages as soon as it receives them
 	for {
-		msgType, msg, err := c.conn.read()
-		if msgType == -1 { // msgType == -1 is noFrame (close connection)
-			return
-		}
+		msgType, msg, err := c.conn.Read()
 		if err != nil {
-			errs <- errors.Wrapf(err, "Receive message type: %d", msgType)
+			select {
+			case <-quit: // The connection was closed on purpose
+				return
+			default:
+			}
+			if c.canReconnect(quit) {
+				if err = c.reconnect(quit); err == nil {
+					continue
+				}
+				select {
+				case <-quit: // The connection was closed on purpose while reconnecting
+					return
+				default:
+				}
+			} else {
+				err = errors.Wrapf(err, "Receive message type: %d", msgType)
+			}
+			c.disconnect()
+			errs <- err
 			c.Errored = true
-			break
+			return
+		}
+		if msgType == -1 { // msgType == -1 is noFrame (close connection)
+			return
 		}
 		if msg != nil {
 			c.handleResponse(msg)
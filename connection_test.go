@@ -1,6 +1,23 @@
 package gremtune
 
-import "testing"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
 
 func TestPanicOnMissingAuthCredentials(t *testing.T) {
 	c := newClient()
@@ -13,5 +30,342 @@ func TestPanicOnMissingAuthCredentials(t *testing.T) {
 		}
 	}()
 
-	c.conn.getAuth()
+	c.conn.Auth()
+}
+
+// firstRequests matches the first n requests the server receives, counting every request it is consulted for,
+// which it is for all of them when registered last
+func firstRequests(n int) gremtunetest.Matcher {
+	seen := 0 // The server is locked while matching
+	return func(gremtunetest.Request) bool {
+		seen++
+		return seen <= n
+	}
+}
+
+// waitForRequests waits until the server received n requests, and returns them
+func waitForRequests(t *testing.T, srv *gremtunetest.Server, n int) []gremtunetest.Request {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if requests := srv.Requests(); len(requests) >= n {
+			return requests
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d requests, got %d", n, len(srv.Requests()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// requestArg returns the decoded argument of the request, nil when missing
+func requestArg(r gremtunetest.Request, name string) interface{} {
+	var v interface{}
+	json.Unmarshal(r.Args[name], &v)
+	return v
+}
+
+func TestReconnectPolicyBackoff(t *testing.T) {
+	p := ReconnectPolicy{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Multiplier: 2}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempt, want := range expected {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("Expected backoff of %v for attempt %d, got %v", want, attempt, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < time.Second || got > 3*time.Second {
+			t.Fatalf("Expected jittered backoff within [1s, 3s], got %v", got)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Success(`["ok"]`))
+	srv.On(firstRequests(1), gremtunetest.Disconnected()) // Drop the first connection while a request is in flight
+
+	errs := make(chan error, 1)
+	dialer := NewDialer(srv.URL(), SetReconnectPolicy(ReconnectPolicy{InitialInterval: 10 * time.Millisecond, MaxAttempts: 5}))
+	c, err := Dial(dialer, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = c.ExecuteContext(ctx, "g.V()")
+	if errors.Cause(err) != ErrConnectionLost {
+		t.Fatalf("Expected %v, got %v", ErrConnectionLost, err)
+	}
+
+	resp, err := c.ExecuteContext(ctx, "g.V()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 || string(resp[0].Result.Data) != `["ok"]` {
+		t.Errorf("Unexpected response after reconnecting: %+v", resp)
+	}
+
+	select {
+	case err := <-errs:
+		t.Errorf("Expected no connection error to be reported, got %v", err)
+	default:
+	}
+}
+
+// TestCloseWhileReconnecting tests that closing the client while it waits to reconnect reports no error
+func TestCloseWhileReconnecting(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Disconnected()) // Drop the connection while the request is in flight
+
+	errs := make(chan error, 1)
+	c, err := Dial(NewDialer(srv.URL(), SetReconnectPolicy(ReconnectPolicy{InitialInterval: time.Second, MaxAttempts: 5})), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Execute("g.V()"); errors.Cause(err) != ErrConnectionLost {
+		t.Fatalf("Expected %v, got %v", ErrConnectionLost, err)
+	}
+	c.Close() // The client is waiting for its first reconnect attempt
+
+	select {
+	case err := <-errs:
+		t.Errorf("Expected no error to be reported after closing, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestConnectionLostFailsPending(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Disconnected()) // Drop the connection while the request is in flight
+
+	errs := make(chan error, 1)
+	c, err := Dial(NewDialer(srv.URL()), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = c.ExecuteContext(ctx, "g.V()")
+	if errors.Cause(err) != ErrConnectionClosed {
+		t.Fatalf("Expected %v, got %v", ErrConnectionClosed, err)
+	}
+	select {
+	case <-errs:
+	case <-ctx.Done():
+		t.Error("Expected the lost connection to be reported")
+	}
+
+	// Requests sent afterwards fail straight away
+	_, err = c.ExecuteContext(ctx, "g.V()")
+	if errors.Cause(err) != ErrConnectionClosed {
+		t.Errorf("Expected %v, got %v", ErrConnectionClosed, err)
+	}
+}
+
+func TestCloseFailsPending(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Dropped()) // Never answer
+
+	errs := make(chan error, 1)
+	c, err := Dial(NewDialer(srv.URL()), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseChannel := make(chan AsyncResponse, 1)
+	if err = c.ExecuteAsync("g.V()", responseChannel); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := c.Execute("g.V()")
+		done <- err
+	}()
+	waitForRequests(t, srv, 2)
+
+	c.Close()
+
+	select {
+	case err = <-done:
+		if errors.Cause(err) != ErrConnectionClosed {
+			t.Errorf("Expected %v, got %v", ErrConnectionClosed, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected waiting request to be failed on close")
+	}
+
+	r, ok := <-responseChannel
+	if !ok || r.ErrorMessage != ErrConnectionClosed.Error() {
+		t.Errorf("Expected async response carrying %q, got %+v", ErrConnectionClosed.Error(), r)
+	}
+	if _, ok = <-responseChannel; ok {
+		t.Error("Expected async response channel to be closed")
+	}
+	select {
+	case err = <-errs:
+		t.Errorf("Expected closing on purpose not to be reported, got %v", err)
+	default:
+	}
+}
+
+func TestHandshakeHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	var provided int32
+	var proxied int32
+	dialer := NewDialer("ws"+strings.TrimPrefix(srv.URL, "http"),
+		SetHeaders(http.Header{"X-Api-Key": {"static"}, "X-Trace": {"static"}}),
+		SetHeaderProvider(func() (http.Header, error) {
+			atomic.AddInt32(&provided, 1)
+			return http.Header{"x-trace": {"dynamic"}}, nil
+		}),
+		SetProxy(func(*http.Request) (*neturl.URL, error) {
+			atomic.AddInt32(&proxied, 1)
+			return nil, nil // Connect directly
+		}))
+	if err := dialer.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	header := <-received
+	if header.Get("X-Api-Key") != "static" {
+		t.Errorf("Expected static header, got %q", header.Get("X-Api-Key"))
+	}
+	if header.Get("X-Trace") != "dynamic" {
+		t.Errorf("Expected provided header to win over static one, got %q", header.Get("X-Trace"))
+	}
+	if atomic.LoadInt32(&provided) != 1 || atomic.LoadInt32(&proxied) != 1 {
+		t.Errorf("Expected header provider and proxy to be consulted once, got %d and %d", provided, proxied)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+	url := "wss" + strings.TrimPrefix(srv.URL, "https")
+
+	if err := NewDialer(url).Connect(); err == nil {
+		t.Error("Expected the test server certificate not to be trusted by default")
+	}
+
+	config := &tls.Config{RootCAs: x509.NewCertPool()}
+	config.RootCAs.AddCert(srv.Certificate())
+	if err := NewDialer(url, SetTLSConfig(config)).Connect(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewDialerEndpoint(t *testing.T) {
+	cases := []struct {
+		host     string
+		expected string
+		valid    bool
+	}{
+		{"ws://127.0.0.1:8182", "ws://127.0.0.1:8182/gremlin", true},
+		{"wss://neptune.example.com:8182/gremlin", "wss://neptune.example.com:8182/gremlin", true},
+		{"ws://127.0.0.1:8182/", "ws://127.0.0.1:8182/", true},
+		{"127.0.0.1:8182", "", false},
+		{"http://127.0.0.1:8182", "", false},
+		{"ws://", "", false},
+	}
+	for _, tc := range cases {
+		dialer := NewDialer(tc.host)
+		if tc.valid && (dialer.err != nil || dialer.host != tc.expected) {
+			t.Errorf("Expected %s to dial %s, got %s (%v)", tc.host, tc.expected, dialer.host, dialer.err)
+		}
+		if !tc.valid && dialer.Connect() == nil {
+			t.Errorf("Expected %s to be rejected", tc.host)
+		}
+	}
+}
+
+// newRootOnlyServer starts a websocket server only serving the root path, like Gremlin Server before 3.2.2
+func newRootOnlyServer(t *testing.T) (*httptest.Server, string) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "" && r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	return srv, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestPathDiscovery(t *testing.T) {
+	srv, url := newRootOnlyServer(t)
+	defer srv.Close()
+
+	// Without discovery the path is dialed as given and the host left untouched
+	dialer := NewDialer(url)
+	if err := dialer.Connect(); err == nil {
+		t.Fatal("Expected dialing /gremlin to fail")
+	}
+	if err := dialer.Connect(); err == nil || dialer.host != url+"/gremlin" {
+		t.Errorf("Expected host to stay %s, got %s", url+"/gremlin", dialer.host)
+	}
+
+	dialer = NewDialer(url, SetPathDiscovery())
+	if err := dialer.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if dialer.host != url {
+		t.Errorf("Expected discovered url %s, got %s", url, dialer.host)
+	}
+	if err := dialer.Connect(); err != nil || dialer.host != url {
+		t.Errorf("Expected reconnect to dial %s, got %s (%v)", url, dialer.host, err)
+	}
+}
+
+func TestPathDiscoveryFailure(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	err := NewDialer(url, SetPathDiscovery()).Connect()
+	dialErr, ok := err.(*DialError)
+	if !ok {
+		t.Fatalf("Expected a *DialError, got %T: %v", err, err)
+	}
+	expected := []string{url + "/gremlin", url}
+	if len(dialErr.Errors) != 2 || dialErr.URLs[0] != expected[0] || dialErr.URLs[1] != expected[1] {
+		t.Errorf("Expected errors for %v, got %v", expected, dialErr)
+	}
+	if !stderrors.Is(err, dialErr.Errors[0]) {
+		t.Error("Expected the first dial error to be preserved")
+	}
 }
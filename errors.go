@@ -0,0 +1,125 @@
+package gremtune
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/graphson"
+)
+
+// ResponseError is the error of a request Gremlin Server answered with an error status. Errors returned by the
+// client wrap it, use errors.As to get at it or errors.Is to compare it with one of the ErrX sentinels below,
+// which match any ResponseError of the same status code.
+type ResponseError struct {
+	Code       int                    // Code is the status code of the response
+	Message    string                 // Message is the status message of the response
+	Attributes map[string]interface{} // Attributes are the status attributes, such as exceptions and stackTrace
+	RequestID  string                 // RequestID is the id of the failed request
+}
+
+// The ResponseError sentinels, one per error status code of Gremlin Server.
+var (
+	ErrUnauthorized            = &ResponseError{Code: statusUnauthorized}
+	ErrAuthenticate            = &ResponseError{Code: statusAuthenticate}
+	ErrMalformedRequest        = &ResponseError{Code: statusMalformedRequest}
+	ErrInvalidRequestArguments = &ResponseError{Code: statusInvalidRequestArguments}
+	ErrServerError             = &ResponseError{Code: statusServerError}
+	ErrScriptEvaluation        = &ResponseError{Code: statusScriptEvaluationError}
+	ErrServerTimeout           = &ResponseError{Code: statusServerTimeout}
+	ErrServerSerialization     = &ResponseError{Code: statusServerSerializationError}
+)
+
+// errorDescriptions describe the error status codes in error messages
+var errorDescriptions = map[int]string{
+	statusUnauthorized:             "UNAUTHORIZED",
+	statusAuthenticate:             "AUTHENTICATE",
+	statusMalformedRequest:         "MALFORMED REQUEST",
+	statusInvalidRequestArguments:  "INVALID REQUEST ARGUMENTS",
+	statusServerError:              "SERVER ERROR",
+	statusScriptEvaluationError:    "SCRIPT EVALUATION ERROR",
+	statusServerTimeout:            "SERVER TIMEOUT",
+	statusServerSerializationError: "SERVER SERIALIZATION ERROR",
+}
+
+// retryableExceptions are the exceptions of requests that failed for the moment and may succeed if sent again
+var retryableExceptions = map[string]bool{
+	"java.util.ConcurrentModificationException": true,
+}
+
+func (e *ResponseError) Error() string {
+	description, ok := errorDescriptions[e.Code]
+	if !ok {
+		description = "UNKNOWN ERROR"
+	}
+	return fmt.Sprintf("%s - Response Message: %s", description, e.Message)
+}
+
+// Is reports whether target is a ResponseError of the same status code, so that errors.Is(err, ErrServerTimeout)
+// holds for every server timeout.
+func (e *ResponseError) Is(target error) bool {
+	t, ok := target.(*ResponseError)
+	return ok && t.Code == e.Code
+}
+
+// Exceptions returns the class names of the exceptions that caused the error, most specific first, when the
+// server reports them.
+func (e *ResponseError) Exceptions() []string {
+	var items []interface{}
+	switch v := e.attribute("exceptions").(type) {
+	case []interface{}:
+		items = v
+	case graphson.List:
+		items = v
+	}
+	exceptions := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			exceptions = append(exceptions, s)
+		}
+	}
+	return exceptions
+}
+
+// StackTrace returns the stack trace of the error on the server, when the server reports it.
+func (e *ResponseError) StackTrace() string {
+	s, _ := e.attribute("stackTrace").(string)
+	return s
+}
+
+// attribute returns a status attribute. GraphSON 3.0 writes the attributes as a g:Map, which is decoded first.
+func (e *ResponseError) attribute(key string) interface{} {
+	if e.Attributes["@type"] != "g:Map" {
+		return e.Attributes[key]
+	}
+	raw, err := json.Marshal(e.Attributes)
+	if err != nil {
+		return nil
+	}
+	decoded, err := graphson.Unmarshal(raw)
+	if err != nil {
+		return nil
+	}
+	m, _ := decoded.(graphson.Map)
+	value, _ := m.Get(key)
+	return value
+}
+
+// IsTimeout reports whether err is a server timeout or the deadline of the context of the request.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrServerTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsUnauthorized reports whether Gremlin Server refused the credentials of the client.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsRetryable reports whether the request failed for the moment and may succeed if sent again: the connection was
+// lost while the client reconnects, or the server rejected or rolled back the request as a whole, such as on a
+// conflict with a concurrent request or on throttling by Neptune. See DefaultRetryable for which requests are
+// safe to send again.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrConnectionLost) || isTransient(err)
+}
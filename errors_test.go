@@ -0,0 +1,74 @@
+package gremtune
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+// TestResponseErrorFromServer tests that the error of a failed request can be inspected through the errors the
+// client wraps it in
+func TestResponseErrorFromServer(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Reply{Code: statusScriptEvaluationError, Message: "conflict", Attributes: map[string]interface{}{
+		"@type":  "g:Map",
+		"@value": []interface{}{"exceptions", map[string]interface{}{"@type": "g:List", "@value": []string{"java.util.ConcurrentModificationException"}}, "stackTrace", "at x"},
+	}})
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.Execute("g.V()")
+	var re *ResponseError
+	if !errors.As(err, &re) {
+		t.Fatalf("Expected a ResponseError, got %v", err)
+	}
+	if re.Code != statusScriptEvaluationError || re.Message != "conflict" || re.RequestID == "" {
+		t.Errorf("Unexpected error %+v", re)
+	}
+	if exceptions := re.Exceptions(); len(exceptions) != 1 || exceptions[0] != "java.util.ConcurrentModificationException" {
+		t.Errorf("Expected the exception of the error, got %v", exceptions)
+	}
+	if re.StackTrace() != "at x" {
+		t.Errorf("Expected the stack trace of the error, got %q", re.StackTrace())
+	}
+	if !errors.Is(err, ErrScriptEvaluation) || errors.Is(err, ErrServerTimeout) {
+		t.Error("Expected the error to match the sentinel of its code only")
+	}
+	if !IsRetryable(err) {
+		t.Error("Expected a concurrent modification to be retryable")
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	cases := []struct {
+		err          error
+		timeout      bool
+		unauthorized bool
+		retryable    bool
+	}{
+		{errors.Wrap(&ResponseError{Code: statusServerTimeout}, "query"), true, false, false},
+		{errors.Wrap(context.DeadlineExceeded, "query"), true, false, false},
+		{&ResponseError{Code: statusUnauthorized}, false, true, false},
+		{&ResponseError{Code: statusServerError, Attributes: map[string]interface{}{"exceptions": []interface{}{"java.util.ConcurrentModificationException"}}}, false, false, true},
+		{errors.Wrap(ErrConnectionLost, "query"), false, false, true},
+		{errors.New("other"), false, false, false},
+	}
+	for _, tc := range cases {
+		if IsTimeout(tc.err) != tc.timeout {
+			t.Errorf("Expected IsTimeout(%v) to be %v", tc.err, tc.timeout)
+		}
+		if IsUnauthorized(tc.err) != tc.unauthorized {
+			t.Errorf("Expected IsUnauthorized(%v) to be %v", tc.err, tc.unauthorized)
+		}
+		if IsRetryable(tc.err) != tc.retryable {
+			t.Errorf("Expected IsRetryable(%v) to be %v", tc.err, tc.retryable)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package graphbinary
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/graphson"
+)
+
+// Unmarshal decodes a fully qualified GraphBinary value into Go values. Graph elements, paths and collections are
+// decoded to the types of the graphson package, Int to int32, Long to int64, Short to int16, Byte to int8, Float to
+// float32, Double to float64, Date and Timestamp to time.Time, UUID to uuid.UUID, BigInteger to *big.Int,
+// ByteBuffer to []byte and Duration to time.Duration. T and Direction are decoded to graphson.T and
+// graphson.Direction, the other enums to their name.
+func Unmarshal(data []byte) (interface{}, error) {
+	r := &reader{data: data}
+	v, err := r.readValue()
+	if err == nil && r.pos != len(data) {
+		err = errors.Errorf("%d bytes left after the value", len(data)-r.pos)
+	}
+	return v, err
+}
+
+// reader reads GraphBinary values from a buffer
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) readByte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) readShort() (int16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *reader) readInt() (int32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *reader) readLong() (int64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (r *reader) readLength() (int, error) {
+	n, err := r.readInt()
+	if err == nil && n < 0 {
+		err = errors.Errorf("negative length %d", n)
+	}
+	return int(n), err
+}
+
+// readCount reads the number of items of a collection. Every item takes at least a byte, so a count larger than
+// the bytes left is refused before anything is allocated for it.
+func (r *reader) readCount() (int, error) {
+	n, err := r.readLength()
+	if err == nil && n > len(r.data)-r.pos {
+		err = errors.Errorf("%d items do not fit in the %d bytes left", n, len(r.data)-r.pos)
+	}
+	return n, err
+}
+
+func (r *reader) readString() (string, error) {
+	n, err := r.readLength()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.next(n)
+	return string(b), err
+}
+
+func (r *reader) readUUID() (uuid.UUID, error) {
+	b, err := r.next(16)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.FromBytes(b)
+}
+
+// readValue reads a fully qualified value: its type code, its value flag and, unless it is null, the value
+func (r *reader) readValue() (interface{}, error) {
+	code, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if code == customType {
+		return nil, errors.New("custom types are not supported")
+	}
+	flag, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if flag&nullFlag != 0 {
+		return nil, nil
+	}
+	v, err := r.readBare(code, flag)
+	return v, errors.Wrapf(err, "decoding type 0x%02x", code)
+}
+
+// readBare reads the value of a type, the part after the type code and value flag
+func (r *reader) readBare(code, flag byte) (interface{}, error) {
+	switch code {
+	case intType:
+		return r.readInt()
+	case longType:
+		return r.readLong()
+	case shortType:
+		return r.readShort()
+	case byteType:
+		b, err := r.readByte()
+		return int8(b), err
+	case booleanType:
+		b, err := r.readByte()
+		return b != 0, err
+	case floatType:
+		i, err := r.readInt()
+		return math.Float32frombits(uint32(i)), err
+	case doubleType:
+		i, err := r.readLong()
+		return math.Float64frombits(uint64(i)), err
+	case stringType, classType:
+		return r.readString()
+	case charType:
+		return r.readChar()
+	case dateType, timestampType:
+		ms, err := r.readLong()
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC(), err
+	case durationType:
+		seconds, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		nanos, err := r.readInt()
+		return time.Duration(seconds)*time.Second + time.Duration(nanos), err
+	case uuidType:
+		return r.readUUID()
+	case bigIntegerType:
+		n, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(n)
+		return twosComplement(b), err
+	case byteBufferType:
+		n, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(n)
+		return append([]byte(nil), b...), err
+	case listType:
+		items, err := r.readList(flag&bulkFlag != 0)
+		return graphson.List(items), err
+	case setType:
+		items, err := r.readList(false)
+		return graphson.Set(items), err
+	case mapType:
+		return r.readMap()
+	case bulkSetType:
+		return r.readBulkSet()
+	case vertexType:
+		return r.readVertex()
+	case edgeType:
+		return r.readEdge()
+	case vertexPropertyType:
+		return r.readVertexProperty()
+	case propertyType:
+		return r.readProperty()
+	case pathType:
+		return r.readPath()
+	case traverserType:
+		return r.readTraverser()
+	case tType:
+		s, err := r.readEnum()
+		return graphson.T(s), err
+	case directionType:
+		s, err := r.readEnum()
+		return graphson.Direction(s), err
+	case barrierType, cardinalityType, columnType, operatorType, orderType, pickType, popType, scopeType:
+		return r.readEnum()
+	case unspecifiedNull:
+		return nil, nil
+	}
+	return nil, errors.New("unsupported type")
+}
+
+// readChar reads a UTF-8 encoded character, whose first byte tells its length
+func (r *reader) readChar() (string, error) {
+	first, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	n := 1
+	switch {
+	case first&0xe0 == 0xc0:
+		n = 2
+	case first&0xf0 == 0xe0:
+		n = 3
+	case first&0xf8 == 0xf0:
+		n = 4
+	}
+	rest, err := r.next(n - 1)
+	if err != nil {
+		return "", err
+	}
+	s := string(append([]byte{first}, rest...))
+	if !utf8.ValidString(s) {
+		return "", errors.Errorf("invalid character %q", s)
+	}
+	return s, nil
+}
+
+// twosComplement returns the big-endian two's complement number
+func twosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+// readEnum reads the name of an enum value, which is written as a fully qualified string
+func (r *reader) readEnum() (string, error) {
+	v, err := r.readValue()
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf("enum value is %T, not a String", v)
+	}
+	return s, nil
+}
+
+func (r *reader) readList(bulked bool) ([]interface{}, error) {
+	n, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		if !bulked {
+			items = append(items, v)
+			continue
+		}
+		bulk, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		for ; bulk > 0; bulk-- {
+			items = append(items, v)
+		}
+	}
+	return items, nil
+}
+
+func (r *reader) readMap() (graphson.Map, error) {
+	n, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	m := make(graphson.Map, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, graphson.MapEntry{Key: key, Value: value})
+	}
+	return m, nil
+}
+
+func (r *reader) readBulkSet() (graphson.BulkSet, error) {
+	n, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	s := make(graphson.BulkSet, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		bulk, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, graphson.BulkItem{Value: v, Bulk: bulk})
+	}
+	return s, nil
+}
+
+// readProperties reads the properties of an element, a list of the given type or null
+func (r *reader) readProperties() ([]interface{}, error) {
+	v, err := r.readValue()
+	if err != nil || v == nil {
+		return nil, err
+	}
+	list, ok := v.(graphson.List)
+	if !ok {
+		return nil, errors.Errorf("properties are %T, not a List", v)
+	}
+	return list, nil
+}
+
+func (r *reader) readVertex() (v graphson.Vertex, err error) {
+	if v.ID, err = r.readValue(); err != nil {
+		return
+	}
+	if v.Label, err = r.readString(); err != nil {
+		return
+	}
+	props, err := r.readProperties()
+	if err != nil || props == nil {
+		return
+	}
+	v.Properties = make(map[string][]graphson.VertexProperty)
+	for _, p := range props {
+		vp, ok := p.(graphson.VertexProperty)
+		if !ok {
+			return v, errors.Errorf("vertex property is %T, not a VertexProperty", p)
+		}
+		v.Properties[vp.Label] = append(v.Properties[vp.Label], vp)
+	}
+	return
+}
+
+func (r *reader) readEdge() (e graphson.Edge, err error) {
+	if e.ID, err = r.readValue(); err != nil {
+		return
+	}
+	if e.Label, err = r.readString(); err != nil {
+		return
+	}
+	if e.InV, err = r.readValue(); err != nil {
+		return
+	}
+	if e.InVLabel, err = r.readString(); err != nil {
+		return
+	}
+	if e.OutV, err = r.readValue(); err != nil {
+		return
+	}
+	if e.OutVLabel, err = r.readString(); err != nil {
+		return
+	}
+	if _, err = r.readValue(); err != nil { // The parent is always null
+		return
+	}
+	props, err := r.readProperties()
+	if err != nil || props == nil {
+		return
+	}
+	e.Properties = make(map[string]graphson.Property, len(props))
+	for _, p := range props {
+		prop, ok := p.(graphson.Property)
+		if !ok {
+			return e, errors.Errorf("edge property is %T, not a Property", p)
+		}
+		e.Properties[prop.Key] = prop
+	}
+	return
+}
+
+func (r *reader) readVertexProperty() (p graphson.VertexProperty, err error) {
+	if p.ID, err = r.readValue(); err != nil {
+		return
+	}
+	if p.Label, err = r.readString(); err != nil {
+		return
+	}
+	if p.Value, err = r.readValue(); err != nil {
+		return
+	}
+	if _, err = r.readValue(); err != nil { // The parent is always null
+		return
+	}
+	props, err := r.readProperties()
+	if err != nil || props == nil {
+		return
+	}
+	p.Properties = make(map[string]interface{}, len(props))
+	for _, mp := range props {
+		prop, ok := mp.(graphson.Property)
+		if !ok {
+			return p, errors.Errorf("meta-property is %T, not a Property", mp)
+		}
+		p.Properties[prop.Key] = prop.Value
+	}
+	return
+}
+
+func (r *reader) readProperty() (p graphson.Property, err error) {
+	if p.Key, err = r.readString(); err != nil {
+		return
+	}
+	if p.Value, err = r.readValue(); err != nil {
+		return
+	}
+	_, err = r.readValue() // The parent is always null
+	return
+}
+
+func (r *reader) readPath() (p graphson.Path, err error) {
+	labels, err := r.readValue()
+	if err != nil {
+		return
+	}
+	objects, err := r.readValue()
+	if err != nil {
+		return
+	}
+	labelSets, ok := labels.(graphson.List)
+	if !ok {
+		return p, errors.Errorf("labels are %T, not a List", labels)
+	}
+	for _, ls := range labelSets {
+		set, ok := ls.(graphson.Set)
+		if !ok {
+			return p, errors.Errorf("labels of a step are %T, not a Set", ls)
+		}
+		stepLabels := make([]string, 0, len(set))
+		for _, l := range set {
+			s, ok := l.(string)
+			if !ok {
+				return p, errors.Errorf("label is %T, not a String", l)
+			}
+			stepLabels = append(stepLabels, s)
+		}
+		p.Labels = append(p.Labels, stepLabels)
+	}
+	list, ok := objects.(graphson.List)
+	if !ok {
+		return p, errors.Errorf("objects are %T, not a List", objects)
+	}
+	p.Objects = list
+	return
+}
+
+func (r *reader) readTraverser() (t graphson.Traverser, err error) {
+	if t.Bulk, err = r.readLong(); err != nil {
+		return
+	}
+	t.Value, err = r.readValue()
+	return
+}
@@ -0,0 +1,173 @@
+package graphbinary
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/schwartzmx/gremtune/graphson"
+)
+
+// Fixtures follow the examples of the GraphBinary 1.0 section of the TinkerPop IO reference: a type code, a value
+// flag and the value, integers big-endian and strings prefixed with their length.
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func str(s string) []byte { // A bare String
+	return join([]byte{0, 0, 0, byte(len(s))}, []byte(s))
+}
+
+var (
+	intOne    = []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x01}
+	markoUUID = uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786"))
+)
+
+var goldenValues = []struct {
+	name     string
+	data     []byte
+	expected interface{}
+}{
+	{"Int", intOne, int32(1)},
+	{"null Int", []byte{0x01, 0x01}, nil},
+	{"Long", []byte{0x02, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, int64(-1)},
+	{"String", join([]byte{0x03, 0x00}, str("abc")), "abc"},
+	{"Date", []byte{0x04, 0x00, 0x00, 0x00, 0x01, 0x58, 0xff, 0x2f, 0xdb, 0x87}, time.Unix(1481750076, 295000000).UTC()},
+	{"Double", []byte{0x07, 0x00, 0x40, 0x59, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00}, float64(100.5)},
+	{"Float", []byte{0x08, 0x00, 0x42, 0xc9, 0x00, 0x00}, float32(100.5)},
+	{"Boolean", []byte{0x27, 0x00, 0x01}, true},
+	{"Short", []byte{0x26, 0x00, 0x01, 0x00}, int16(256)},
+	{"Byte", []byte{0x24, 0x00, 0xff}, int8(-1)},
+	{"UUID", join([]byte{0x0c, 0x00}, markoUUID.Bytes()), markoUUID},
+	{"BigInteger", []byte{0x23, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7f}, big.NewInt(-129)},
+	{"ByteBuffer", []byte{0x25, 0x00, 0x00, 0x00, 0x00, 0x02, 0xca, 0xfe}, []byte{0xca, 0xfe}},
+	{"Duration", []byte{0x31, 0x00, 0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 1}, 5*time.Second + time.Nanosecond},
+	{"T", join([]byte{0x20, 0x00, 0x03, 0x00}, str("id")), graphson.T("id")},
+	{"Direction", join([]byte{0x18, 0x00, 0x03, 0x00}, str("OUT")), graphson.Direction("OUT")},
+	{"List", join([]byte{0x09, 0x00, 0, 0, 0, 2}, intOne, []byte{0x03, 0x00}, str("a")), graphson.List{int32(1), "a"}},
+	{"Set", join([]byte{0x0b, 0x00, 0, 0, 0, 1}, intOne), graphson.Set{int32(1)}},
+	{"Map", join([]byte{0x0a, 0x00, 0, 0, 0, 1}, []byte{0x03, 0x00}, str("name"), []byte{0x09, 0x00, 0, 0, 0, 1}, []byte{0x03, 0x00}, str("marko")),
+		graphson.Map{{Key: "name", Value: graphson.List{"marko"}}}},
+	{"BulkSet", join([]byte{0x2a, 0x00, 0, 0, 0, 1}, []byte{0x03, 0x00}, str("lop"), []byte{0, 0, 0, 0, 0, 0, 0, 3}),
+		graphson.BulkSet{{Value: "lop", Bulk: 3}}},
+	{"Traverser", join([]byte{0x21, 0x00, 0, 0, 0, 0, 0, 0, 0, 2}, []byte{0x03, 0x00}, str("lop")), graphson.Traverser{Bulk: 2, Value: "lop"}},
+	{"Vertex", join([]byte{0x11, 0x00}, intOne, str("person"), []byte{0xfe, 0x01}), graphson.Vertex{ID: int32(1), Label: "person"}},
+	{"Edge", join([]byte{0x0d, 0x00}, []byte{0x01, 0x00, 0, 0, 0, 13}, str("develops"), []byte{0x01, 0x00, 0, 0, 0, 10}, str("software"),
+		intOne, str("person"), []byte{0xfe, 0x01}, []byte{0xfe, 0x01}),
+		graphson.Edge{ID: int32(13), Label: "develops", InV: int32(10), InVLabel: "software", OutV: int32(1), OutVLabel: "person"}},
+	{"Property", join([]byte{0x0f, 0x00}, str("since"), []byte{0x01, 0x00, 0, 0, 0x07, 0xd9}, []byte{0xfe, 0x01}),
+		graphson.Property{Key: "since", Value: int32(2009)}},
+	{"VertexProperty", join([]byte{0x12, 0x00}, []byte{0x02, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}, str("name"), []byte{0x03, 0x00}, str("marko"),
+		[]byte{0xfe, 0x01}, []byte{0xfe, 0x01}), graphson.VertexProperty{ID: int64(0), Label: "name", Value: "marko"}},
+	{"Path", join([]byte{0x0e, 0x00},
+		[]byte{0x09, 0x00, 0, 0, 0, 2}, []byte{0x0b, 0x00, 0, 0, 0, 1, 0x03, 0x00}, str("a"), []byte{0x0b, 0x00, 0, 0, 0, 0},
+		[]byte{0x09, 0x00, 0, 0, 0, 2, 0x03, 0x00}, str("marko"), []byte{0x03, 0x00}, str("lop")),
+		graphson.Path{Labels: [][]string{{"a"}, {}}, Objects: graphson.List{"marko", "lop"}}},
+}
+
+func TestUnmarshalGolden(t *testing.T) {
+	for _, tc := range goldenValues {
+		v, err := Unmarshal(tc.data)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tc.name, tc.expected, v)
+		}
+	}
+}
+
+func TestMarshalGolden(t *testing.T) {
+	for _, tc := range goldenValues {
+		if tc.expected == nil { // Go nil is written as the unspecified null
+			continue
+		}
+		data, err := Marshal(tc.expected)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(data, tc.data) {
+			t.Errorf("%s: expected % x, got % x", tc.name, tc.data, data)
+		}
+	}
+}
+
+// TestUnmarshalBulkedList tests that the items of a bulked list are repeated as often as their bulk says
+func TestUnmarshalBulkedList(t *testing.T) {
+	data := join([]byte{0x09, 0x02, 0, 0, 0, 1}, []byte{0x03, 0x00}, str("a"), []byte{0, 0, 0, 0, 0, 0, 0, 2})
+	v, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, graphson.List{"a", "a"}) {
+		t.Errorf("Expected the item twice, got %#v", v)
+	}
+}
+
+// TestMarshalRoundTrip tests that the values with properties read back as they were written
+func TestMarshalRoundTrip(t *testing.T) {
+	values := []interface{}{
+		graphson.Vertex{ID: int64(1), Label: "person", Properties: map[string][]graphson.VertexProperty{
+			"name": {{ID: int64(0), Label: "name", Value: "marko", Properties: map[string]interface{}{"since": int32(2009)}}},
+		}},
+		graphson.Edge{ID: int32(7), Label: "knows", InV: int32(2), OutV: int32(1),
+			Properties: map[string]graphson.Property{"weight": {Key: "weight", Value: 0.5}}},
+		big.NewInt(-256),
+		big.NewInt(128),
+	}
+	for _, v := range values {
+		data, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, v) {
+			t.Errorf("Expected %#v, got %#v", v, decoded)
+		}
+	}
+}
+
+// TestMarshalReflected tests that other slices and maps are written as a List and a Map with sorted keys
+func TestMarshalReflected(t *testing.T) {
+	data, err := Marshal(map[string]string{"b": "2", "a": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (graphson.Map{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+	if _, err := Marshal(struct{}{}); err == nil {
+		t.Error("Expected an error encoding a struct")
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x01, 0x00, 0x00}, // Truncated Int
+		append(append([]byte{}, intOne...), 0x00), // Trailing byte
+		{0x00, 0x00},                         // Custom type
+		{0x7f, 0x00},                         // Unknown type
+		{0x09, 0x00, 0xff, 0xff, 0xff, 0xff}, // Negative length
+		{0x09, 0x00, 0x7f, 0xff, 0xff, 0xff}, // List longer than the data
+		{0x0a, 0x00, 0x7f, 0xff, 0xff, 0xff}, // Map longer than the data
+		{0x2a, 0x00, 0x7f, 0xff, 0xff, 0xff}, // Bulk set longer than the data
+	} {
+		if _, err := Unmarshal(data); err == nil {
+			t.Errorf("Expected error decoding % x", data)
+		}
+	}
+}
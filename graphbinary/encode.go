@@ -0,0 +1,368 @@
+package graphbinary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// Marshal encodes a Go value as a fully qualified GraphBinary value. It is the reverse of Unmarshal, with int
+// written as an Int when it fits, as Groovy and Java would, and a Long otherwise. Traversals are written as
+// Bytecode, with their tokens and predicates. Other slices and arrays are written as a List and other maps as a
+// Map, keys sorted by their string form.
+func Marshal(v interface{}) ([]byte, error) {
+	w := &writer{}
+	err := w.writeValue(v)
+	return w.Bytes(), err
+}
+
+// writer writes GraphBinary values to a buffer
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) header(code byte) {
+	w.WriteByte(code)
+	w.WriteByte(valueFlag)
+}
+
+func (w *writer) writeNull() {
+	w.WriteByte(unspecifiedNull)
+	w.WriteByte(nullFlag)
+}
+
+func (w *writer) writeShort(i int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(i))
+	w.Write(b[:])
+}
+
+func (w *writer) writeInt(i int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(i))
+	w.Write(b[:])
+}
+
+func (w *writer) writeLong(i int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(i))
+	w.Write(b[:])
+}
+
+func (w *writer) writeString(s string) {
+	w.writeInt(int32(len(s)))
+	w.WriteString(s)
+}
+
+// writeValue writes a fully qualified value: its type code, its value flag and the value
+func (w *writer) writeValue(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		w.writeNull()
+	case bool:
+		w.header(booleanType)
+		if v {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	case int8:
+		w.header(byteType)
+		w.WriteByte(byte(v))
+	case int16:
+		w.header(shortType)
+		w.writeShort(v)
+	case int32:
+		w.header(intType)
+		w.writeInt(v)
+	case int:
+		if int(int32(v)) == v {
+			w.header(intType)
+			w.writeInt(int32(v))
+		} else {
+			w.header(longType)
+			w.writeLong(int64(v))
+		}
+	case int64:
+		w.header(longType)
+		w.writeLong(v)
+	case float32:
+		w.header(floatType)
+		w.writeInt(int32(math.Float32bits(v)))
+	case float64:
+		w.header(doubleType)
+		w.writeLong(int64(math.Float64bits(v)))
+	case string:
+		w.header(stringType)
+		w.writeString(v)
+	case time.Time:
+		w.header(dateType)
+		w.writeLong(v.UnixNano() / int64(time.Millisecond))
+	case time.Duration:
+		w.header(durationType)
+		w.writeLong(int64(v / time.Second))
+		w.writeInt(int32(v % time.Second))
+	case uuid.UUID:
+		w.header(uuidType)
+		w.Write(v.Bytes())
+	case *big.Int:
+		w.header(bigIntegerType)
+		b := twosComplementBytes(v)
+		w.writeInt(int32(len(b)))
+		w.Write(b)
+	case []byte:
+		w.header(byteBufferType)
+		w.writeInt(int32(len(v)))
+		w.Write(v)
+	case graphson.T:
+		w.header(tType)
+		return w.writeValue(string(v))
+	case graphson.Direction:
+		w.header(directionType)
+		return w.writeValue(string(v))
+	case graphson.List:
+		w.header(listType)
+		return w.writeItems(v)
+	case graphson.Set:
+		w.header(setType)
+		return w.writeItems(v)
+	case graphson.Map:
+		w.header(mapType)
+		w.writeInt(int32(len(v)))
+		for _, e := range v {
+			if err := w.writeValue(e.Key); err != nil {
+				return err
+			}
+			if err := w.writeValue(e.Value); err != nil {
+				return err
+			}
+		}
+	case graphson.BulkSet:
+		w.header(bulkSetType)
+		w.writeInt(int32(len(v)))
+		for _, item := range v {
+			if err := w.writeValue(item.Value); err != nil {
+				return err
+			}
+			w.writeLong(item.Bulk)
+		}
+	case graphson.Vertex:
+		w.header(vertexType)
+		return w.writeVertex(v)
+	case graphson.Edge:
+		w.header(edgeType)
+		return w.writeEdge(v)
+	case graphson.VertexProperty:
+		w.header(vertexPropertyType)
+		return w.writeVertexProperty(v)
+	case graphson.Property:
+		w.header(propertyType)
+		return w.writeProperty(v)
+	case graphson.Path:
+		w.header(pathType)
+		return w.writePath(v)
+	case graphson.Traverser:
+		w.header(traverserType)
+		w.writeLong(v.Bulk)
+		return w.writeValue(v.Value)
+	case traversal.Token:
+		code, ok := enumTypes[v.Enum()]
+		if !ok {
+			return errors.Errorf("unknown enum %s", v.Enum())
+		}
+		w.header(code)
+		return w.writeValue(v.Name())
+	case traversal.Predicate:
+		if v.Class() == "TextP" {
+			w.header(textPType)
+		} else {
+			w.header(pType)
+		}
+		w.writeString(v.Operator())
+		return w.writeItems(v.Args())
+	case *traversal.Traversal:
+		w.header(bytecodeType)
+		if err := w.writeInstructions(v.Steps()); err != nil {
+			return err
+		}
+		return w.writeInstructions(v.Sources())
+	default:
+		return w.writeReflected(v)
+	}
+	return nil
+}
+
+// writeReflected writes the slices, arrays and maps of other types as a List and a Map
+func (w *writer) writeReflected(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		w.header(listType)
+		return w.writeItems(items)
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		m := make(graphson.Map, len(keys))
+		for i, key := range keys {
+			m[i] = graphson.MapEntry{Key: key.Interface(), Value: rv.MapIndex(key).Interface()}
+		}
+		return w.writeValue(m)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			w.writeNull()
+			return nil
+		}
+		return w.writeValue(rv.Elem().Interface())
+	}
+	return errors.Errorf("cannot encode %T as GraphBinary", v)
+}
+
+func (w *writer) writeItems(items []interface{}) error {
+	w.writeInt(int32(len(items)))
+	for _, item := range items {
+		if err := w.writeValue(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeInstructions writes the steps of bytecode, each its name followed by its arguments
+func (w *writer) writeInstructions(steps []traversal.Step) error {
+	w.writeInt(int32(len(steps)))
+	for _, s := range steps {
+		w.writeString(s.Name)
+		if err := w.writeItems(s.Args); err != nil {
+			return errors.Wrapf(err, "step %s", s.Name)
+		}
+	}
+	return nil
+}
+
+// twosComplementBytes returns the shortest big-endian two's complement bytes of the number
+func twosComplementBytes(n *big.Int) []byte {
+	if n.Sign() >= 0 {
+		b := n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	length := (n.BitLen()+7)/8 + 1 // Room for the sign bit
+	b := new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), uint(length*8))).Bytes()
+	for len(b) > 1 && b[0] == 0xff && b[1]&0x80 != 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// writeProperties writes the properties of an element as a List, or null when there are none
+func (w *writer) writeProperties(props []interface{}) error {
+	if props == nil {
+		w.writeNull()
+		return nil
+	}
+	return w.writeValue(graphson.List(props))
+}
+
+func (w *writer) writeVertex(v graphson.Vertex) error {
+	if err := w.writeValue(v.ID); err != nil {
+		return err
+	}
+	w.writeString(v.Label)
+	var props []interface{}
+	for _, key := range sortedKeys(v.Properties) {
+		for _, vp := range v.Properties[key] {
+			props = append(props, vp)
+		}
+	}
+	return w.writeProperties(props)
+}
+
+func (w *writer) writeEdge(e graphson.Edge) error {
+	if err := w.writeValue(e.ID); err != nil {
+		return err
+	}
+	w.writeString(e.Label)
+	if err := w.writeValue(e.InV); err != nil {
+		return err
+	}
+	w.writeString(e.InVLabel)
+	if err := w.writeValue(e.OutV); err != nil {
+		return err
+	}
+	w.writeString(e.OutVLabel)
+	w.writeNull() // Parent
+	var props []interface{}
+	for _, key := range sortedKeys(e.Properties) {
+		props = append(props, e.Properties[key])
+	}
+	return w.writeProperties(props)
+}
+
+func (w *writer) writeVertexProperty(p graphson.VertexProperty) error {
+	if err := w.writeValue(p.ID); err != nil {
+		return err
+	}
+	w.writeString(p.Label)
+	if err := w.writeValue(p.Value); err != nil {
+		return err
+	}
+	w.writeNull() // Parent
+	var props []interface{}
+	for _, key := range sortedKeys(p.Properties) {
+		props = append(props, graphson.Property{Key: key, Value: p.Properties[key]})
+	}
+	return w.writeProperties(props)
+}
+
+func (w *writer) writeProperty(p graphson.Property) error {
+	w.writeString(p.Key)
+	if err := w.writeValue(p.Value); err != nil {
+		return err
+	}
+	w.writeNull() // Parent
+	return nil
+}
+
+func (w *writer) writePath(p graphson.Path) error {
+	labels := make(graphson.List, len(p.Labels))
+	for i, stepLabels := range p.Labels {
+		set := make(graphson.Set, len(stepLabels))
+		for j, l := range stepLabels {
+			set[j] = l
+		}
+		labels[i] = set
+	}
+	if err := w.writeValue(labels); err != nil {
+		return err
+	}
+	return w.writeValue(graphson.List(p.Objects))
+}
+
+// sortedKeys returns the keys of a map keyed by strings in order
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	sorted := make([]string, len(keys))
+	for i, key := range keys {
+		sorted[i] = key.String()
+	}
+	sort.Strings(sorted)
+	return sorted
+}
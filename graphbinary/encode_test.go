@@ -0,0 +1,39 @@
+package graphbinary
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// TestMarshalBytecode tests that traversals are written as Bytecode: the steps then the sources, each the name of
+// the step followed by its arguments
+func TestMarshalBytecode(t *testing.T) {
+	tr := traversal.G.WithSideEffect("a", int32(1)).V().Has("age", traversal.Gt(int32(29))).By(traversal.T.ID)
+	data, err := Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := join(
+		[]byte{0x15, 0x00},
+		[]byte{0, 0, 0, 3},
+		str("V"), []byte{0, 0, 0, 0},
+		str("has"), []byte{0, 0, 0, 2}, []byte{0x03, 0x00}, str("age"),
+		[]byte{0x1e, 0x00}, str("gt"), []byte{0, 0, 0, 1, 0x01, 0x00, 0, 0, 0, 29},
+		str("by"), []byte{0, 0, 0, 1}, []byte{0x20, 0x00, 0x03, 0x00}, str("id"),
+		[]byte{0, 0, 0, 1},
+		str("withSideEffect"), []byte{0, 0, 0, 2}, []byte{0x03, 0x00}, str("a"), intOne,
+	)
+	if !bytes.Equal(data, expected) {
+		t.Errorf("Expected % x, got % x", expected, data)
+	}
+
+	data, err = Marshal(traversal.StartingWith("ma"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := join([]byte{0x28, 0x00}, str("startingWith"), []byte{0, 0, 0, 1, 0x03, 0x00}, str("ma")); !bytes.Equal(data, expected) {
+		t.Errorf("Expected % x, got % x", expected, data)
+	}
+}
@@ -0,0 +1,161 @@
+package graphbinary
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// RequestMessage is a request to Gremlin Server.
+type RequestMessage struct {
+	RequestID uuid.UUID
+	Op        string
+	Processor string
+	Args      map[string]interface{}
+}
+
+// ResponseMessage is a response of Gremlin Server. RequestID is uuid.Nil when the server could not tell which
+// request the response is for.
+type ResponseMessage struct {
+	RequestID        uuid.UUID
+	StatusCode       int
+	StatusMessage    string
+	StatusAttributes map[string]interface{}
+	ResultMeta       map[string]interface{}
+	ResultData       interface{}
+}
+
+// EncodeRequest writes the request message, without the mime type header that precedes it on the websocket.
+func EncodeRequest(req RequestMessage) ([]byte, error) {
+	w := &writer{}
+	w.WriteByte(version)
+	w.Write(req.RequestID.Bytes())
+	w.writeString(req.Op)
+	w.writeString(req.Processor)
+	if err := w.writeMap(req.Args); err != nil {
+		return nil, errors.Wrap(err, "encoding request arguments")
+	}
+	return w.Bytes(), nil
+}
+
+// DecodeRequest reads a request message written by EncodeRequest.
+func DecodeRequest(data []byte) (req RequestMessage, err error) {
+	r := &reader{data: data}
+	if err = r.readVersion(); err != nil {
+		return
+	}
+	if req.RequestID, err = r.readUUID(); err != nil {
+		return
+	}
+	if req.Op, err = r.readString(); err != nil {
+		return
+	}
+	if req.Processor, err = r.readString(); err != nil {
+		return
+	}
+	req.Args, err = r.readStringMap()
+	return req, errors.Wrap(err, "decoding request")
+}
+
+// EncodeResponse writes the response message.
+func EncodeResponse(resp ResponseMessage) ([]byte, error) {
+	w := &writer{}
+	w.WriteByte(version)
+	if resp.RequestID == uuid.Nil {
+		w.WriteByte(nullFlag)
+	} else {
+		w.WriteByte(valueFlag)
+		w.Write(resp.RequestID.Bytes())
+	}
+	w.writeInt(int32(resp.StatusCode))
+	if resp.StatusMessage == "" {
+		w.WriteByte(nullFlag)
+	} else {
+		w.WriteByte(valueFlag)
+		w.writeString(resp.StatusMessage)
+	}
+	if err := w.writeMap(resp.StatusAttributes); err != nil {
+		return nil, errors.Wrap(err, "encoding status attributes")
+	}
+	if err := w.writeMap(resp.ResultMeta); err != nil {
+		return nil, errors.Wrap(err, "encoding result meta")
+	}
+	if err := w.writeValue(resp.ResultData); err != nil {
+		return nil, errors.Wrap(err, "encoding result data")
+	}
+	return w.Bytes(), nil
+}
+
+// DecodeResponse reads a response message of Gremlin Server.
+func DecodeResponse(data []byte) (resp ResponseMessage, err error) {
+	r := &reader{data: data}
+	err = r.readResponse(&resp)
+	if err == nil && r.pos != len(data) {
+		err = errors.Errorf("%d bytes left after the response", len(data)-r.pos)
+	}
+	return resp, errors.Wrap(err, "decoding response")
+}
+
+func (r *reader) readResponse(resp *ResponseMessage) (err error) {
+	if err = r.readVersion(); err != nil {
+		return
+	}
+	flag, err := r.readByte()
+	if err != nil {
+		return
+	}
+	if flag&nullFlag == 0 {
+		if resp.RequestID, err = r.readUUID(); err != nil {
+			return
+		}
+	}
+	code, err := r.readInt()
+	if err != nil {
+		return
+	}
+	resp.StatusCode = int(code)
+	if flag, err = r.readByte(); err != nil {
+		return
+	}
+	if flag&nullFlag == 0 {
+		if resp.StatusMessage, err = r.readString(); err != nil {
+			return
+		}
+	}
+	if resp.StatusAttributes, err = r.readStringMap(); err != nil {
+		return
+	}
+	if resp.ResultMeta, err = r.readStringMap(); err != nil {
+		return
+	}
+	resp.ResultData, err = r.readValue()
+	return
+}
+
+func (r *reader) readVersion() error {
+	v, err := r.readByte()
+	if err == nil && v != version {
+		err = errors.Errorf("unsupported version 0x%02x", v)
+	}
+	return err
+}
+
+// readStringMap reads a bare map, keyed by the string form of its keys
+func (r *reader) readStringMap() (map[string]interface{}, error) {
+	m, err := r.readMap()
+	if err != nil {
+		return nil, err
+	}
+	return m.StringMap(), nil
+}
+
+// writeMap writes a bare map, keys sorted
+func (w *writer) writeMap(m map[string]interface{}) error {
+	w.writeInt(int32(len(m)))
+	for _, key := range sortedKeys(m) {
+		w.writeValue(key)
+		if err := w.writeValue(m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
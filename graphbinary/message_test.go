@@ -0,0 +1,107 @@
+package graphbinary
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/schwartzmx/gremtune/graphson"
+)
+
+var requestID = uuid.Must(uuid.FromString("cb682578-9d92-4499-9ebc-5c6aa73c5397"))
+
+// evalRequest is the eval request of g.V(x) with x bound to 1
+var evalRequest = join(
+	[]byte{0x81},
+	requestID.Bytes(),
+	str("eval"),
+	str(""),
+	[]byte{0, 0, 0, 3},
+	[]byte{0x03, 0x00}, str("bindings"), []byte{0x0a, 0x00, 0, 0, 0, 1, 0x03, 0x00}, str("x"), intOne,
+	[]byte{0x03, 0x00}, str("gremlin"), []byte{0x03, 0x00}, str("g.V(x)"),
+	[]byte{0x03, 0x00}, str("language"), []byte{0x03, 0x00}, str("gremlin-groovy"),
+)
+
+// successResponse is the response to evalRequest with the vertex marko
+var successResponse = join(
+	[]byte{0x81},
+	[]byte{0x00}, requestID.Bytes(),
+	[]byte{0, 0, 0, 200},
+	[]byte{0x01},
+	[]byte{0, 0, 0, 1}, []byte{0x03, 0x00}, str("host"), []byte{0x03, 0x00}, str("/127.0.0.1:62975"),
+	[]byte{0, 0, 0, 0},
+	[]byte{0x09, 0x00, 0, 0, 0, 1, 0x11, 0x00}, intOne, str("person"), []byte{0xfe, 0x01},
+)
+
+func TestEncodeRequest(t *testing.T) {
+	req := RequestMessage{
+		RequestID: requestID,
+		Op:        "eval",
+		Args: map[string]interface{}{
+			"gremlin":  "g.V(x)",
+			"bindings": map[string]interface{}{"x": int32(1)},
+			"language": "gremlin-groovy",
+		},
+	}
+	data, err := EncodeRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, evalRequest) {
+		t.Errorf("Expected % x, got % x", evalRequest, data)
+	}
+
+	decoded, err := DecodeRequest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Args["bindings"] = graphson.Map{{Key: "x", Value: int32(1)}}
+	if !reflect.DeepEqual(decoded, req) {
+		t.Errorf("Expected %#v, got %#v", req, decoded)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	resp, err := DecodeResponse(successResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ResponseMessage{
+		RequestID:        requestID,
+		StatusCode:       200,
+		StatusAttributes: map[string]interface{}{"host": "/127.0.0.1:62975"},
+		ResultMeta:       map[string]interface{}{},
+		ResultData:       graphson.List{graphson.Vertex{ID: int32(1), Label: "person"}},
+	}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, resp)
+	}
+
+	data, err := EncodeResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, successResponse) {
+		t.Errorf("Expected % x, got % x", successResponse, data)
+	}
+}
+
+func TestDecodeResponseError(t *testing.T) {
+	data, err := EncodeResponse(ResponseMessage{StatusCode: 597, StatusMessage: "No such property: x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := DecodeResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RequestID != uuid.Nil || resp.StatusCode != 597 || resp.StatusMessage != "No such property: x" || resp.ResultData != nil {
+		t.Errorf("Unexpected response %#v", resp)
+	}
+
+	data[0] = 0x80
+	if _, err := DecodeResponse(data); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+}
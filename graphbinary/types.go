@@ -0,0 +1,71 @@
+// Package graphbinary reads and writes GraphBinary 1.0, the binary format of Gremlin Server, and its request and
+// response messages. Values are decoded to the same Go values as graphson decodes GraphSON to, so results read in
+// either format can be handled alike.
+package graphbinary
+
+// MimeType is the mime type Gremlin Server serves GraphBinary 1.0 under.
+const MimeType = "application/vnd.graphbinary-v1.0"
+
+// version is the first byte of every message, the version with its highest bit set
+const version byte = 0x81
+
+// Value flags
+const (
+	valueFlag byte = 0x00
+	nullFlag  byte = 0x01
+	bulkFlag  byte = 0x02 // bulkFlag marks a list whose items are each followed by their bulk
+)
+
+// Type codes
+const (
+	intType            byte = 0x01
+	longType           byte = 0x02
+	stringType         byte = 0x03
+	dateType           byte = 0x04
+	timestampType      byte = 0x05
+	classType          byte = 0x06
+	doubleType         byte = 0x07
+	floatType          byte = 0x08
+	listType           byte = 0x09
+	mapType            byte = 0x0a
+	setType            byte = 0x0b
+	uuidType           byte = 0x0c
+	edgeType           byte = 0x0d
+	pathType           byte = 0x0e
+	propertyType       byte = 0x0f
+	vertexType         byte = 0x11
+	vertexPropertyType byte = 0x12
+	barrierType        byte = 0x13
+	bytecodeType       byte = 0x15
+	cardinalityType    byte = 0x16
+	columnType         byte = 0x17
+	directionType      byte = 0x18
+	operatorType       byte = 0x19
+	orderType          byte = 0x1a
+	pickType           byte = 0x1b
+	popType            byte = 0x1c
+	pType              byte = 0x1e
+	scopeType          byte = 0x1f
+	tType              byte = 0x20
+	traverserType      byte = 0x21
+	bigIntegerType     byte = 0x23
+	byteType           byte = 0x24
+	byteBufferType     byte = 0x25
+	shortType          byte = 0x26
+	booleanType        byte = 0x27
+	textPType          byte = 0x28
+	bulkSetType        byte = 0x2a
+	charType           byte = 0x30
+	durationType       byte = 0x31
+	customType         byte = 0x00
+	unspecifiedNull    byte = 0xfe
+)
+
+// enumTypes maps the enums of traversal tokens to their type codes
+var enumTypes = map[string]byte{
+	"T":           tType,
+	"Order":       orderType,
+	"Cardinality": cardinalityType,
+	"Scope":       scopeType,
+	"Column":      columnType,
+}
@@ -0,0 +1,510 @@
+package graphson
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// envelope is the wrapper GraphSON puts around typed values
+type envelope struct {
+	Type  string          `json:"@type"`
+	Value json.RawMessage `json:"@value"`
+}
+
+// Version is a version of GraphSON.
+type Version int
+
+// The versions of GraphSON the decoder understands.
+const (
+	V1 Version = 1
+	V2 Version = 2
+	V3 Version = 3
+)
+
+// Unmarshal decodes GraphSON 3.0 data into Go values. Typed values are decoded to the types of this package,
+// gx:Byte to int8, gx:Int16 to int16, g:Int32 to int32, g:Int64 to int64, g:Float to float32, g:Double to float64, g:Date and g:Timestamp to
+// time.Time and g:UUID to uuid.UUID. Values of unknown types are returned as Typed.
+func Unmarshal(data []byte) (interface{}, error) {
+	return UnmarshalVersion(data, V3)
+}
+
+// UnmarshalVersion decodes data of the given GraphSON version into the same Go values as Unmarshal. GraphSON 2.0
+// has no g:List and g:Map, JSON arrays and objects are decoded to List and Map instead. GraphSON 1.0 has no types
+// at all: objects of type vertex and edge are decoded to Vertex and Edge, whole numbers to int64 and other numbers
+// to float64.
+func UnmarshalVersion(data []byte, version Version) (interface{}, error) {
+	if version < V1 || version > V3 {
+		return nil, errors.Errorf("unknown GraphSON version %d", version)
+	}
+	return decoder{version: version}.decode(data)
+}
+
+// decoder decodes one version of GraphSON
+type decoder struct {
+	version Version
+}
+
+func (d decoder) decode(data json.RawMessage) (interface{}, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	switch data[0] {
+	case '{':
+		keys, values, err := objectEntries(data)
+		if err != nil {
+			return nil, err
+		}
+		if d.version > V1 && len(keys) <= 2 && contains(keys, "@type") {
+			var e envelope
+			if err := json.Unmarshal(data, &e); err != nil {
+				return nil, err
+			}
+			return d.decodeTyped(e)
+		}
+		if d.version == V1 {
+			if v, ok, err := d.decodeElement(data); ok || err != nil {
+				return v, err
+			}
+		}
+		m := make(Map, len(keys))
+		for i, key := range keys {
+			v, err := d.decode(values[i])
+			if err != nil {
+				return nil, err
+			}
+			m[i] = MapEntry{Key: key, Value: v}
+		}
+		return m, nil
+	case '[':
+		items, err := d.decodeList(data)
+		return List(items), err
+	default:
+		if d.version == V1 {
+			return decodeUntypedNumber(data)
+		}
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+}
+
+// objectEntries returns the keys of a JSON object and their values in the order they appear
+func objectEntries(data json.RawMessage) (keys []string, values []json.RawMessage, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err = dec.Token(); err != nil { // Opening brace
+		return
+	}
+	for dec.More() {
+		var t json.Token
+		if t, err = dec.Token(); err != nil {
+			return
+		}
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return
+		}
+		keys = append(keys, t.(string))
+		values = append(values, value)
+	}
+	return
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeUntypedNumber decodes a GraphSON 1.0 scalar, whole numbers become int64 as the type is not known
+func decodeUntypedNumber(data json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	return n.Float64()
+}
+
+// decodeElement decodes a GraphSON 1.0 vertex or edge, reporting whether the object was one
+func (d decoder) decodeElement(data json.RawMessage) (v interface{}, ok bool, err error) {
+	var kind struct {
+		Type string `json:"type"`
+	}
+	if err = json.Unmarshal(data, &kind); err != nil {
+		return
+	}
+	switch kind.Type {
+	case "vertex":
+		v, err = d.decodeVertex(data)
+		return v, true, err
+	case "edge":
+		v, err = d.decodeEdge(data)
+		return v, true, err
+	}
+	return nil, false, nil
+}
+
+func (d decoder) decodeObject(fields map[string]json.RawMessage) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(fields))
+	for k, raw := range fields {
+		v, err := d.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		obj[k] = v
+	}
+	return obj, nil
+}
+
+func (d decoder) decodeItems(items []json.RawMessage) ([]interface{}, error) {
+	values := make([]interface{}, len(items))
+	for i, raw := range items {
+		v, err := d.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (d decoder) decodeTyped(e envelope) (v interface{}, err error) {
+	switch e.Type {
+	case "gx:Byte":
+		var i int8
+		err = json.Unmarshal(e.Value, &i)
+		v = i
+	case "gx:Int16":
+		var i int16
+		err = json.Unmarshal(e.Value, &i)
+		v = i
+	case "g:Int32":
+		var i int32
+		err = json.Unmarshal(e.Value, &i)
+		v = i
+	case "g:Int64":
+		var i int64
+		err = json.Unmarshal(e.Value, &i)
+		v = i
+	case "g:Float":
+		var f float64
+		f, err = decodeFloat(e.Value)
+		v = float32(f)
+	case "g:Double":
+		v, err = decodeFloat(e.Value)
+	case "g:Date", "g:Timestamp":
+		var ms int64
+		if err = json.Unmarshal(e.Value, &ms); err == nil {
+			v = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+		}
+	case "g:UUID":
+		var s string
+		if err = json.Unmarshal(e.Value, &s); err == nil {
+			v, err = uuid.FromString(s)
+		}
+	case "g:T":
+		var s string
+		err = json.Unmarshal(e.Value, &s)
+		v = T(s)
+	case "g:Direction":
+		var s string
+		err = json.Unmarshal(e.Value, &s)
+		v = Direction(s)
+	case "g:List":
+		var items []interface{}
+		items, err = d.decodeList(e.Value)
+		v = List(items)
+	case "g:Set":
+		var items []interface{}
+		items, err = d.decodeList(e.Value)
+		v = Set(items)
+	case "g:Map":
+		v, err = d.decodeMap(e.Value)
+	case "g:BulkSet":
+		v, err = d.decodeBulkSet(e.Value)
+	case "g:Vertex":
+		v, err = d.decodeVertex(e.Value)
+	case "g:Edge":
+		v, err = d.decodeEdge(e.Value)
+	case "g:VertexProperty":
+		v, err = d.decodeVertexProperty(e.Value)
+	case "g:Property":
+		v, err = d.decodeProperty(e.Value)
+	case "g:Path":
+		v, err = d.decodePath(e.Value)
+	case "g:Traverser":
+		v, err = d.decodeTraverser(e.Value)
+	default:
+		v = Typed{Type: e.Type, Value: e.Value}
+	}
+	return v, errors.Wrapf(err, "decoding %s", e.Type)
+}
+
+// decodeFloat decodes a floating point number, which GraphSON writes as a string when it is not finite
+func decodeFloat(data json.RawMessage) (float64, error) {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		switch s {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		return 0, errors.Errorf("invalid number %q", s)
+	}
+	var f float64
+	err := json.Unmarshal(data, &f)
+	return f, err
+}
+
+func (d decoder) decodeList(data json.RawMessage) ([]interface{}, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return d.decodeItems(items)
+}
+
+func (d decoder) decodeMap(data json.RawMessage) (Map, error) {
+	items, err := d.decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(items)%2 != 0 {
+		return nil, errors.New("odd number of keys and values")
+	}
+	m := make(Map, 0, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		m = append(m, MapEntry{Key: items[i], Value: items[i+1]})
+	}
+	return m, nil
+}
+
+func (d decoder) decodeBulkSet(data json.RawMessage) (BulkSet, error) {
+	items, err := d.decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(items)%2 != 0 {
+		return nil, errors.New("odd number of values and bulks")
+	}
+	s := make(BulkSet, 0, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		bulk, ok := items[i+1].(int64)
+		if !ok {
+			return nil, errors.Errorf("bulk of %v is %T, not g:Int64", items[i], items[i+1])
+		}
+		s = append(s, BulkItem{Value: items[i], Bulk: bulk})
+	}
+	return s, nil
+}
+
+func (d decoder) decodeVertex(data json.RawMessage) (v Vertex, err error) {
+	var raw struct {
+		ID         json.RawMessage              `json:"id"`
+		Label      string                       `json:"label"`
+		Properties map[string][]json.RawMessage `json:"properties"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	v.Label = raw.Label
+	if v.ID, err = d.decode(raw.ID); err != nil {
+		return
+	}
+	if raw.Properties != nil {
+		v.Properties = make(map[string][]VertexProperty, len(raw.Properties))
+	}
+	for key, values := range raw.Properties {
+		for _, rawValue := range values {
+			if d.version == V1 { // Vertex properties are plain objects without a label
+				var vp VertexProperty
+				if vp, err = d.decodeVertexProperty(rawValue); err != nil {
+					return
+				}
+				vp.Label = key
+				v.Properties[key] = append(v.Properties[key], vp)
+				continue
+			}
+			var p interface{}
+			if p, err = d.decode(rawValue); err != nil {
+				return
+			}
+			vp, ok := p.(VertexProperty)
+			if !ok {
+				err = errors.Errorf("property %s is %T, not g:VertexProperty", key, p)
+				return
+			}
+			v.Properties[key] = append(v.Properties[key], vp)
+		}
+	}
+	return
+}
+
+func (d decoder) decodeEdge(data json.RawMessage) (e Edge, err error) {
+	var raw struct {
+		ID         json.RawMessage            `json:"id"`
+		Label      string                     `json:"label"`
+		InV        json.RawMessage            `json:"inV"`
+		InVLabel   string                     `json:"inVLabel"`
+		OutV       json.RawMessage            `json:"outV"`
+		OutVLabel  string                     `json:"outVLabel"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	e.Label, e.InVLabel, e.OutVLabel = raw.Label, raw.InVLabel, raw.OutVLabel
+	if e.ID, err = d.decode(raw.ID); err != nil {
+		return
+	}
+	if e.InV, err = d.decode(raw.InV); err != nil {
+		return
+	}
+	if e.OutV, err = d.decode(raw.OutV); err != nil {
+		return
+	}
+	if raw.Properties != nil {
+		e.Properties = make(map[string]Property, len(raw.Properties))
+	}
+	for key, rawValue := range raw.Properties {
+		var p interface{}
+		if p, err = d.decode(rawValue); err != nil {
+			return
+		}
+		if d.version == V1 { // Edge properties are the bare values
+			e.Properties[key] = Property{Key: key, Value: p}
+			continue
+		}
+		prop, ok := p.(Property)
+		if !ok {
+			err = errors.Errorf("property %s is %T, not g:Property", key, p)
+			return
+		}
+		e.Properties[key] = prop
+	}
+	return
+}
+
+func (d decoder) decodeVertexProperty(data json.RawMessage) (p VertexProperty, err error) {
+	var raw struct {
+		ID         json.RawMessage            `json:"id"`
+		Label      string                     `json:"label"`
+		Value      json.RawMessage            `json:"value"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	p.Label = raw.Label
+	if p.ID, err = d.decode(raw.ID); err != nil {
+		return
+	}
+	if p.Value, err = d.decode(raw.Value); err != nil {
+		return
+	}
+	if raw.Properties != nil {
+		p.Properties, err = d.decodeObject(raw.Properties)
+	}
+	return
+}
+
+func (d decoder) decodeProperty(data json.RawMessage) (p Property, err error) {
+	var raw struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	p.Key = raw.Key
+	p.Value, err = d.decode(raw.Value)
+	return
+}
+
+func (d decoder) decodePath(data json.RawMessage) (p Path, err error) {
+	var raw struct {
+		Labels  json.RawMessage `json:"labels"`
+		Objects json.RawMessage `json:"objects"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	labels, err := d.decode(raw.Labels)
+	if err != nil {
+		return
+	}
+	objects, err := d.decode(raw.Objects)
+	if err != nil {
+		return
+	}
+	labelSets, ok := labels.(List)
+	if !ok {
+		return p, errors.Errorf("labels are %T, not g:List", labels)
+	}
+	for _, ls := range labelSets {
+		var set []interface{}
+		switch ls := ls.(type) {
+		case Set:
+			set = ls
+		case List: // GraphSON 1.0 and 2.0 write the labels of a step as an array
+			set = ls
+		default:
+			return p, errors.Errorf("labels of a step are %T, not g:Set", ls)
+		}
+		stepLabels := make([]string, 0, len(set))
+		for _, l := range set {
+			s, ok := l.(string)
+			if !ok {
+				return p, errors.Errorf("label is %T, not a string", l)
+			}
+			stepLabels = append(stepLabels, s)
+		}
+		p.Labels = append(p.Labels, stepLabels)
+	}
+	list, ok := objects.(List)
+	if !ok {
+		return p, errors.Errorf("objects are %T, not g:List", objects)
+	}
+	p.Objects = list
+	return
+}
+
+func (d decoder) decodeTraverser(data json.RawMessage) (t Traverser, err error) {
+	var raw struct {
+		Bulk  json.RawMessage `json:"bulk"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	bulk, err := d.decode(raw.Bulk)
+	if err != nil {
+		return
+	}
+	var ok bool
+	if t.Bulk, ok = bulk.(int64); !ok {
+		return t, errors.Errorf("bulk is %T, not g:Int64", bulk)
+	}
+	t.Value, err = d.decode(raw.Value)
+	return
+}
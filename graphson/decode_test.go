@@ -0,0 +1,247 @@
+package graphson
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Fixtures are taken from the GraphSON 3.0 section of the TinkerPop IO reference
+
+var vertexFixture = []byte(`{
+  "@type" : "g:Vertex",
+  "@value" : {
+    "id" : { "@type" : "g:Int32", "@value" : 1 },
+    "label" : "person",
+    "properties" : {
+      "name" : [ {
+        "@type" : "g:VertexProperty",
+        "@value" : { "id" : { "@type" : "g:Int64", "@value" : 0 }, "value" : "marko", "label" : "name" }
+      } ],
+      "location" : [ {
+        "@type" : "g:VertexProperty",
+        "@value" : {
+          "id" : { "@type" : "g:Int64", "@value" : 6 },
+          "value" : "san diego",
+          "label" : "location",
+          "properties" : {
+            "startTime" : { "@type" : "g:Int32", "@value" : 1997 },
+            "endTime" : { "@type" : "g:Int32", "@value" : 2001 }
+          }
+        }
+      } ]
+    }
+  }
+}`)
+
+var edgeFixture = []byte(`{
+  "@type" : "g:Edge",
+  "@value" : {
+    "id" : { "@type" : "g:Int32", "@value" : 13 },
+    "label" : "develops",
+    "inVLabel" : "software",
+    "outVLabel" : "person",
+    "inV" : { "@type" : "g:Int32", "@value" : 10 },
+    "outV" : { "@type" : "g:Int32", "@value" : 1 },
+    "properties" : {
+      "since" : { "@type" : "g:Property", "@value" : { "key" : "since", "value" : { "@type" : "g:Int32", "@value" : 2009 } } }
+    }
+  }
+}`)
+
+var pathFixture = []byte(`{
+  "@type" : "g:Path",
+  "@value" : {
+    "labels" : { "@type" : "g:List", "@value" : [ { "@type" : "g:Set", "@value" : [ "a" ] }, { "@type" : "g:Set", "@value" : [ ] } ] },
+    "objects" : { "@type" : "g:List", "@value" : [
+      { "@type" : "g:Vertex", "@value" : { "id" : { "@type" : "g:Int32", "@value" : 1 }, "label" : "person" } },
+      "lop"
+    ] }
+  }
+}`)
+
+func TestUnmarshalVertex(t *testing.T) {
+	v, err := Unmarshal(vertexFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Vertex{
+		ID:    int32(1),
+		Label: "person",
+		Properties: map[string][]VertexProperty{
+			"name": {{ID: int64(0), Label: "name", Value: "marko"}},
+			"location": {{
+				ID:         int64(6),
+				Label:      "location",
+				Value:      "san diego",
+				Properties: map[string]interface{}{"startTime": int32(1997), "endTime": int32(2001)},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+}
+
+func TestUnmarshalEdge(t *testing.T) {
+	e, err := Unmarshal(edgeFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Edge{
+		ID:         int32(13),
+		Label:      "develops",
+		InV:        int32(10),
+		InVLabel:   "software",
+		OutV:       int32(1),
+		OutVLabel:  "person",
+		Properties: map[string]Property{"since": {Key: "since", Value: int32(2009)}},
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, e)
+	}
+}
+
+func TestUnmarshalPath(t *testing.T) {
+	p, err := Unmarshal(pathFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Path{
+		Labels:  [][]string{{"a"}, {}},
+		Objects: []interface{}{Vertex{ID: int32(1), Label: "person"}, "lop"},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, p)
+	}
+}
+
+func TestUnmarshalCollections(t *testing.T) {
+	data := []byte(`{"@type":"g:List","@value":[
+		{"@type":"g:Map","@value":[{"@type":"g:T","@value":"id"},"1234","name",{"@type":"g:List","@value":["Phil"]}]},
+		{"@type":"g:BulkSet","@value":["marko",{"@type":"g:Int64","@value":1},"josh",{"@type":"g:Int64","@value":2}]},
+		{"@type":"g:Set","@value":[{"@type":"g:Direction","@value":"OUT"}]},
+		{"@type":"g:Traverser","@value":{"bulk":{"@type":"g:Int64","@value":3},"value":"lop"}},
+		{"@type":"gx:Char","@value":"a"}
+	]}`)
+	v, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := List{
+		Map{{Key: T("id"), Value: "1234"}, {Key: "name", Value: List{"Phil"}}},
+		BulkSet{{Value: "marko", Bulk: 1}, {Value: "josh", Bulk: 2}},
+		Set{Direction("OUT")},
+		Traverser{Bulk: 3, Value: "lop"},
+		Typed{Type: "gx:Char", Value: []byte(`"a"`)},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+
+	m := v.(List)[0].(Map)
+	if name, ok := m.Get("name"); !ok || !reflect.DeepEqual(name, List{"Phil"}) {
+		t.Errorf("Expected name to be found, got %v", name)
+	}
+	if sm := m.StringMap(); sm["id"] != "1234" {
+		t.Errorf("Expected id key in string map, got %v", sm)
+	}
+}
+
+func TestUnmarshalScalars(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected interface{}
+	}{
+		{`{"@type":"gx:Byte","@value":-100}`, int8(-100)},
+		{`{"@type":"gx:Int16","@value":1000}`, int16(1000)},
+		{`{"@type":"g:Int32","@value":100}`, int32(100)},
+		{`{"@type":"g:Int64","@value":100}`, int64(100)},
+		{`{"@type":"g:Float","@value":100.5}`, float32(100.5)},
+		{`{"@type":"g:Double","@value":100.5}`, float64(100.5)},
+		{`{"@type":"g:Double","@value":"Infinity"}`, math.Inf(1)},
+		{`{"@type":"g:Date","@value":1481750076295}`, time.Unix(1481750076, 295000000).UTC()},
+		{`{"@type":"g:Timestamp","@value":1481750076295}`, time.Unix(1481750076, 295000000).UTC()},
+		{`{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`, uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786"))},
+		{`{"@type":"g:T","@value":"label"}`, T("label")},
+		{`"text"`, "text"},
+		{`true`, true},
+		{`null`, nil},
+	}
+	for _, tc := range cases {
+		v, err := Unmarshal([]byte(tc.data))
+		if err != nil {
+			t.Errorf("Unexpected error decoding %s: %v", tc.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.expected) {
+			t.Errorf("Expected %s to decode to %#v, got %#v", tc.data, tc.expected, v)
+		}
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"@type":"g:Int32","@value":"one"}`,
+		`{"@type":"g:Map","@value":["key"]}`,
+		`{"@type":"g:UUID","@value":"not-a-uuid"}`,
+		`{"@type":"g:Vertex","@value":{"id":1,"properties":{"name":["marko"]}}}`,
+	} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Errorf("Expected error decoding %s", data)
+		}
+	}
+}
+
+func TestUnmarshalVersion2(t *testing.T) {
+	data := []byte(`[
+  { "@type" : "g:Vertex", "@value" : { "id" : { "@type" : "g:Int32", "@value" : 1 }, "label" : "person" } },
+  { "name" : "marko", "age" : { "@type" : "g:Int32", "@value" : 29 } },
+  { "@type" : "g:Path", "@value" : { "labels" : [ [ "a" ], [ ] ], "objects" : [ "marko", "lop" ] } }
+]`)
+	v, err := UnmarshalVersion(data, V2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := List{
+		Vertex{ID: int32(1), Label: "person"},
+		Map{{Key: "name", Value: "marko"}, {Key: "age", Value: int32(29)}},
+		Path{Labels: [][]string{{"a"}, {}}, Objects: List{"marko", "lop"}},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+}
+
+func TestUnmarshalVersion1(t *testing.T) {
+	data := []byte(`[
+  { "id" : 1, "label" : "person", "type" : "vertex", "properties" : { "name" : [ { "id" : 0, "value" : "marko" } ] } },
+  { "id" : 13, "label" : "develops", "type" : "edge", "inVLabel" : "software", "outVLabel" : "person",
+    "inV" : 10, "outV" : 1, "properties" : { "since" : 2009 } },
+  { "@type" : "not a type in 1.0", "weight" : 0.4 }
+]`)
+	v, err := UnmarshalVersion(data, V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := List{
+		Vertex{ID: int64(1), Label: "person", Properties: map[string][]VertexProperty{
+			"name": {{ID: int64(0), Label: "name", Value: "marko"}},
+		}},
+		Edge{ID: int64(13), Label: "develops", InV: int64(10), InVLabel: "software", OutV: int64(1), OutVLabel: "person",
+			Properties: map[string]Property{"since": {Key: "since", Value: int64(2009)}}},
+		Map{{Key: "@type", Value: "not a type in 1.0"}, {Key: "weight", Value: 0.4}},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+}
+
+func TestUnmarshalUnknownVersion(t *testing.T) {
+	if _, err := UnmarshalVersion([]byte(`[]`), Version(4)); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+}
@@ -0,0 +1,242 @@
+package graphson
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// Marshal encodes a Go value as GraphSON 3.0, the reverse of Unmarshal for scalars and collections with int
+// written as a g:Int32 when it fits, as Groovy and Java would, and a g:Int64 otherwise. Traversals are written as
+// g:Bytecode, with their tokens and predicates. Other slices and arrays are written as a g:List and other maps as a
+// g:Map, keys sorted by their string form.
+func Marshal(v interface{}) ([]byte, error) {
+	return MarshalVersion(v, V3)
+}
+
+// MarshalVersion encodes a Go value as the given version of GraphSON. GraphSON 2.0 writes collections as JSON
+// arrays and objects, GraphSON 1.0 writes no types at all and has no bytecode.
+func MarshalVersion(v interface{}, version Version) ([]byte, error) {
+	if version < V1 || version > V3 {
+		return nil, errors.Errorf("unknown GraphSON version %d", version)
+	}
+	value, err := encoder{version: version}.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// encoder encodes Go values into the JSON values of one version of GraphSON
+type encoder struct {
+	version Version
+}
+
+type typed struct {
+	Type  string      `json:"@type"`
+	Value interface{} `json:"@value"`
+}
+
+// typed wraps the value in its type, unless the version has no types
+func (e encoder) typed(typ string, value interface{}) interface{} {
+	if e.version == V1 {
+		return value
+	}
+	return typed{Type: typ, Value: value}
+}
+
+func (e encoder) encode(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case int8:
+		return e.typed("gx:Byte", v), nil
+	case int16:
+		return e.typed("gx:Int16", v), nil
+	case int32:
+		return e.typed("g:Int32", v), nil
+	case int:
+		if int(int32(v)) == v {
+			return e.typed("g:Int32", v), nil
+		}
+		return e.typed("g:Int64", v), nil
+	case int64:
+		return e.typed("g:Int64", v), nil
+	case float32:
+		return e.typed("g:Float", encodeFloat(float64(v))), nil
+	case float64:
+		return e.typed("g:Double", encodeFloat(v)), nil
+	case time.Time:
+		return e.typed("g:Date", v.UnixNano()/int64(time.Millisecond)), nil
+	case uuid.UUID:
+		return e.typed("g:UUID", v.String()), nil
+	case T:
+		return e.typed("g:T", string(v)), nil
+	case Direction:
+		return e.typed("g:Direction", string(v)), nil
+	case List:
+		return e.list("g:List", v)
+	case Set:
+		return e.list("g:Set", v)
+	case Map:
+		return e.encodeMap(v)
+	case json.RawMessage:
+		return v, nil
+	case traversal.Token:
+		if e.version == V1 {
+			return nil, errors.New("GraphSON 1.0 has no tokens")
+		}
+		return e.typed("g:"+v.Enum(), v.Name()), nil
+	case traversal.Predicate:
+		return e.predicate(v)
+	case *traversal.Traversal:
+		return e.bytecode(v)
+	}
+	return e.reflected(v)
+}
+
+// encodeFloat writes the numbers that are not finite as the strings GraphSON uses for them
+func encodeFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+func (e encoder) items(values []interface{}) ([]interface{}, error) {
+	items := make([]interface{}, len(values))
+	for i, v := range values {
+		item, err := e.encode(v)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// list writes a g:List or g:Set, or a JSON array before GraphSON 3.0
+func (e encoder) list(typ string, values []interface{}) (interface{}, error) {
+	items, err := e.items(values)
+	if err != nil || e.version < V3 {
+		return items, err
+	}
+	return typed{Type: typ, Value: items}, nil
+}
+
+// encodeMap writes a g:Map, or a JSON object keyed by the string form of the keys before GraphSON 3.0
+func (e encoder) encodeMap(m Map) (interface{}, error) {
+	if e.version < V3 {
+		obj := make(map[string]interface{}, len(m))
+		for key, value := range m.StringMap() {
+			v, err := e.encode(value)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = v
+		}
+		return obj, nil
+	}
+	flat := make([]interface{}, 0, 2*len(m))
+	for _, entry := range m {
+		flat = append(flat, entry.Key, entry.Value)
+	}
+	items, err := e.items(flat)
+	if err != nil {
+		return nil, err
+	}
+	return typed{Type: "g:Map", Value: items}, nil
+}
+
+// reflected writes the slices, arrays and maps of other types as a list and a map
+func (e encoder) reflected(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make(List, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return e.encode(items)
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		m := make(Map, len(keys))
+		for i, key := range keys {
+			m[i] = MapEntry{Key: key.Interface(), Value: rv.MapIndex(key).Interface()}
+		}
+		return e.encode(m)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return e.encode(rv.Elem().Interface())
+	}
+	return nil, errors.Errorf("cannot encode %T as GraphSON", v)
+}
+
+// predicate writes a g:P or g:TextP, the values of predicates taking several as a list
+func (e encoder) predicate(p traversal.Predicate) (interface{}, error) {
+	if e.version == V1 {
+		return nil, errors.New("GraphSON 1.0 has no predicates")
+	}
+	var value interface{}
+	var err error
+	if args := p.Args(); len(args) == 1 && p.Operator() != "within" && p.Operator() != "without" {
+		value, err = e.encode(args[0])
+	} else {
+		value, err = e.list("g:List", args)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return typed{Type: "g:" + p.Class(), Value: map[string]interface{}{"predicate": p.Operator(), "value": value}}, nil
+}
+
+// bytecode writes a g:Bytecode, each instruction the name of the step followed by its arguments
+func (e encoder) bytecode(t *traversal.Traversal) (interface{}, error) {
+	if e.version == V1 {
+		return nil, errors.New("GraphSON 1.0 has no bytecode")
+	}
+	instructions := func(steps []traversal.Step) ([][]interface{}, error) {
+		encoded := make([][]interface{}, len(steps))
+		for i, s := range steps {
+			args, err := e.items(s.Args)
+			if err != nil {
+				return nil, errors.Wrapf(err, "step %s", s.Name)
+			}
+			encoded[i] = append([]interface{}{s.Name}, args...)
+		}
+		return encoded, nil
+	}
+	value := map[string]interface{}{}
+	if sources := t.Sources(); len(sources) > 0 {
+		encoded, err := instructions(sources)
+		if err != nil {
+			return nil, err
+		}
+		value["source"] = encoded
+	}
+	if steps := t.Steps(); len(steps) > 0 {
+		encoded, err := instructions(steps)
+		if err != nil {
+			return nil, err
+		}
+		value["step"] = encoded
+	}
+	return typed{Type: "g:Bytecode", Value: value}, nil
+}
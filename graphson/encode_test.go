@@ -0,0 +1,92 @@
+package graphson
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+func TestMarshalScalars(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{int32(100), `{"@type":"g:Int32","@value":100}`},
+		{100, `{"@type":"g:Int32","@value":100}`},
+		{math.MaxInt32 + 1, `{"@type":"g:Int64","@value":2147483648}`},
+		{int64(100), `{"@type":"g:Int64","@value":100}`},
+		{float32(100.5), `{"@type":"g:Float","@value":100.5}`},
+		{math.Inf(-1), `{"@type":"g:Double","@value":"-Infinity"}`},
+		{time.Unix(1481750076, 295000000), `{"@type":"g:Date","@value":1481750076295}`},
+		{uuid.Must(uuid.FromString("41d2e28a-20a4-4ab0-b379-d810dede3786")), `{"@type":"g:UUID","@value":"41d2e28a-20a4-4ab0-b379-d810dede3786"}`},
+		{T("label"), `{"@type":"g:T","@value":"label"}`},
+		{[]string{"a"}, `{"@type":"g:List","@value":["a"]}`},
+		{map[string]int32{"b": 2, "a": 1}, `{"@type":"g:Map","@value":["a",{"@type":"g:Int32","@value":1},"b",{"@type":"g:Int32","@value":2}]}`},
+		{"text", `"text"`},
+		{nil, `null`},
+	}
+	for _, tc := range cases {
+		data, err := Marshal(tc.value)
+		if err != nil {
+			t.Errorf("Unexpected error encoding %v: %v", tc.value, err)
+			continue
+		}
+		if string(data) != tc.expected {
+			t.Errorf("Expected %v to encode to %s, got %s", tc.value, tc.expected, data)
+		}
+	}
+}
+
+// TestMarshalRoundTrip tests that Unmarshal decodes what Marshal encodes to the same values
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, value := range []interface{}{int8(-8), int16(1600), int32(100), int64(100), float32(0.5), 0.25, "text", true} {
+		data, err := Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != value {
+			t.Errorf("Expected %T %v back from %s, got %T %v", value, value, data, decoded, decoded)
+		}
+	}
+}
+
+// TestMarshalBytecode tests traversals against the g:Bytecode examples of the TinkerPop IO reference
+func TestMarshalBytecode(t *testing.T) {
+	g := traversal.G.WithSideEffect("x", int32(1))
+	tr := g.V().HasLabel("person").Has("age", traversal.Gt(int32(29))).Order().By("name", traversal.Order.Desc).
+		Where(traversal.Anon().Out("knows").Has("name", traversal.Within("josh", "vadas")))
+	data, err := Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"@type":"g:Bytecode","@value":{` +
+		`"source":[["withSideEffect","x",{"@type":"g:Int32","@value":1}]],` +
+		`"step":[["V"],["hasLabel","person"],` +
+		`["has","age",{"@type":"g:P","@value":{"predicate":"gt","value":{"@type":"g:Int32","@value":29}}}],` +
+		`["order"],["by","name",{"@type":"g:Order","@value":"desc"}],` +
+		`["where",{"@type":"g:Bytecode","@value":{"step":[["out","knows"],` +
+		`["has","name",{"@type":"g:P","@value":{"predicate":"within","value":{"@type":"g:List","@value":["josh","vadas"]}}}]]}}]]}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	data, err = MarshalVersion(traversal.G.V().Has("name", traversal.Within("josh")), V2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"@type":"g:Bytecode","@value":{"step":[["V"],["has","name",{"@type":"g:P","@value":{"predicate":"within","value":["josh"]}}]]}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	if _, err := MarshalVersion(traversal.G.V(), V1); err == nil {
+		t.Error("Expected an error encoding bytecode as GraphSON 1.0")
+	}
+}
@@ -0,0 +1,117 @@
+// Package graphson decodes GraphSON, the JSON format Gremlin Server returns results in, into Go values, and
+// encodes Go values as GraphSON for the bindings and traversals of requests.
+package graphson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Vertex is a g:Vertex. Properties are only set when the server includes them, keyed by property key.
+type Vertex struct {
+	ID         interface{}
+	Label      string
+	Properties map[string][]VertexProperty
+}
+
+// Edge is a g:Edge.
+type Edge struct {
+	ID         interface{}
+	Label      string
+	InV        interface{}
+	InVLabel   string
+	OutV       interface{}
+	OutVLabel  string
+	Properties map[string]Property
+}
+
+// VertexProperty is a g:VertexProperty. Properties holds its meta-properties.
+type VertexProperty struct {
+	ID         interface{}
+	Label      string
+	Value      interface{}
+	Properties map[string]interface{}
+}
+
+// Property is a g:Property, the property of an edge or a meta-property.
+type Property struct {
+	Key   string
+	Value interface{}
+}
+
+// Path is a g:Path. Labels holds the step labels of each object.
+type Path struct {
+	Labels  [][]string
+	Objects []interface{}
+}
+
+// List is a g:List.
+type List []interface{}
+
+// Set is a g:Set.
+type Set []interface{}
+
+// MapEntry is a key and its value in a Map.
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Map is a g:Map. Its keys can be of any type, vertices or lists included, so it is kept as a list of entries in
+// the order they were received.
+type Map []MapEntry
+
+// Get returns the value for the key.
+func (m Map) Get(key interface{}) (interface{}, bool) {
+	for _, e := range m {
+		if reflect.DeepEqual(e.Key, key) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// StringMap returns the map keyed by the string form of its keys, such as the ones of valueMap(true) whose keys
+// are property keys and the T tokens id and label.
+func (m Map) StringMap() map[string]interface{} {
+	sm := make(map[string]interface{}, len(m))
+	for _, e := range m {
+		switch k := e.Key.(type) {
+		case string:
+			sm[k] = e.Value
+		case T:
+			sm[string(k)] = e.Value
+		default:
+			sm[fmt.Sprint(k)] = e.Value
+		}
+	}
+	return sm
+}
+
+// BulkItem is a value in a BulkSet and the number of times it occurs.
+type BulkItem struct {
+	Value interface{}
+	Bulk  int64
+}
+
+// BulkSet is a g:BulkSet.
+type BulkSet []BulkItem
+
+// Traverser is a g:Traverser, a result of a traversal and the number of times it occurs.
+type Traverser struct {
+	Bulk  int64
+	Value interface{}
+}
+
+// T is a g:T token: id, label, key or value.
+type T string
+
+// Direction is a g:Direction: OUT, IN or BOTH.
+type Direction string
+
+// Typed holds a value of a type the decoder does not know, as received.
+type Typed struct {
+	Type  string
+	Value json.RawMessage
+}
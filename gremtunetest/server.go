@@ -0,0 +1,401 @@
+// Package gremtunetest provides a fake Gremlin Server for unit tests, speaking the driver protocol over a websocket
+// on the loopback interface. Tests register the responses to the requests they expect and dial the URL of the
+// server with gremtune:
+//
+//	srv := gremtunetest.NewServer()
+//	defer srv.Close()
+//	srv.On(gremtunetest.Script("g.V().count()"), gremtunetest.Success(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":3}]}`))
+//	client, err := gremtune.Dial(gremtune.NewDialer(srv.URL()), errs)
+//
+// The server reads requests in GraphSON 1.0, 2.0 and 3.0, and writes the data of responses as given.
+package gremtunetest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Status codes of Gremlin Server
+const (
+	StatusSuccess               = 200
+	StatusNoContent             = 204
+	StatusPartialContent        = 206
+	StatusUnauthorized          = 401
+	StatusAuthenticate          = 407
+	StatusMalformedRequest      = 498
+	StatusServerError           = 500
+	StatusScriptEvaluationError = 597
+	StatusServerTimeout         = 598
+)
+
+// Request is a request the server received.
+type Request struct {
+	RequestID string
+	Op        string
+	Processor string
+	Args      map[string]json.RawMessage
+	MimeType  string
+}
+
+// Script returns the script of an eval request.
+func (r Request) Script() string {
+	var script string
+	json.Unmarshal(r.Args["gremlin"], &script)
+	return script
+}
+
+// Steps returns the names of the steps of a bytecode request, sources first.
+func (r Request) Steps() []string {
+	var bytecode struct {
+		Value struct {
+			Source [][]json.RawMessage `json:"source"`
+			Step   [][]json.RawMessage `json:"step"`
+		} `json:"@value"`
+	}
+	if json.Unmarshal(r.Args["gremlin"], &bytecode) != nil {
+		return nil
+	}
+	var steps []string
+	for _, instruction := range append(bytecode.Value.Source, bytecode.Value.Step...) {
+		var name string
+		if len(instruction) > 0 && json.Unmarshal(instruction[0], &name) == nil {
+			steps = append(steps, name)
+		}
+	}
+	return steps
+}
+
+// Binding returns the binding of an eval request decoded into v.
+func (r Request) Binding(name string, v interface{}) error {
+	var bindings map[string]json.RawMessage
+	if err := json.Unmarshal(r.Args["bindings"], &bindings); err != nil {
+		return err
+	}
+	b, ok := bindings[name]
+	if !ok {
+		return fmt.Errorf("no binding %s", name)
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Matcher selects the requests a handler answers.
+type Matcher func(r Request) bool
+
+// Script matches eval requests of the script.
+func Script(script string) Matcher {
+	return func(r Request) bool {
+		return r.Op == "eval" && r.Script() == script
+	}
+}
+
+// ScriptMatching matches eval requests whose script matches the regular expression.
+func ScriptMatching(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(r Request) bool {
+		return r.Op == "eval" && re.MatchString(r.Script())
+	}
+}
+
+// Bytecode matches bytecode requests of the steps, given by name with the sources first, such as "V", "count".
+func Bytecode(steps ...string) Matcher {
+	return func(r Request) bool {
+		return r.Op == "bytecode" && strings.Join(r.Steps(), ".") == strings.Join(steps, ".")
+	}
+}
+
+// Op matches every request of the op, such as close.
+func Op(op string) Matcher {
+	return func(r Request) bool {
+		return r.Op == op
+	}
+}
+
+// Any matches every request.
+func Any() Matcher {
+	return func(Request) bool {
+		return true
+	}
+}
+
+// Reply is one response frame to a request.
+type Reply struct {
+	Code       int
+	Message    string
+	Attributes map[string]interface{}
+	Data       string        // Data is the GraphSON written as the data of the result, null when empty
+	Delay      time.Duration // Delay is waited before writing the reply
+	Drop       bool          // Drop skips writing the reply, the client keeps waiting for it
+	Disconnect bool          // Disconnect closes the connection instead of writing the reply
+}
+
+// Success returns the final reply with the data.
+func Success(data string) Reply {
+	return Reply{Code: StatusSuccess, Data: data}
+}
+
+// Partial returns a partial reply with the data, to be followed by more replies.
+func Partial(data string) Reply {
+	return Reply{Code: StatusPartialContent, Data: data}
+}
+
+// NoContent returns the final reply without data.
+func NoContent() Reply {
+	return Reply{Code: StatusNoContent}
+}
+
+// Error returns a reply failing the request with the status code and message.
+func Error(code int, message string) Reply {
+	return Reply{Code: code, Message: message}
+}
+
+// Delayed returns the reply written after the delay.
+func Delayed(reply Reply, delay time.Duration) Reply {
+	reply.Delay = delay
+	return reply
+}
+
+// Dropped returns a reply that is never written.
+func Dropped() Reply {
+	return Reply{Drop: true}
+}
+
+// Disconnected returns a reply closing the connection.
+func Disconnected() Reply {
+	return Reply{Disconnect: true}
+}
+
+// handler answers the requests its matcher selects with its replies
+type handler struct {
+	match   Matcher
+	replies []Reply
+}
+
+// Server is a fake Gremlin Server. Requests are answered by the last registered handler matching them, close
+// requests without a handler with no content and other requests without a handler with a script evaluation
+// error.
+type Server struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	handlers []handler
+	requests []Request
+	username string
+	password string
+	auth     bool
+	check    func(r *http.Request) error
+	conns    map[*websocket.Conn]struct{}
+}
+
+// NewServer starts a fake Gremlin Server.
+func NewServer() *Server {
+	s := &Server{conns: map[*websocket.Conn]struct{}{}}
+	upgrader := websocket.Upgrader{}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		check := s.check
+		s.mu.Unlock()
+		if check != nil {
+			if err := check(r); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+		s.serve(conn)
+	}))
+	return s
+}
+
+// URL returns the ws:// url of the server.
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Close shuts the server down, closing its connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for conn := range s.conns { // Upgraded connections are no longer tracked by the HTTP server
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// On answers the requests the matcher selects with the replies, one frame each, taking precedence over the
+// handlers registered before. Give partial replies before the final one to stream results.
+func (s *Server) On(match Matcher, replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler{match: match, replies: replies})
+}
+
+// RequireAuth makes the server challenge every request for the SASL PLAIN credentials before answering it, and
+// refuse it if the client answers with others.
+func (s *Server) RequireAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth, s.username, s.password = true, username, password
+}
+
+// CheckHandshake makes the server refuse the websocket handshakes the check fails with 403 Forbidden, such as the
+// ones without the expected headers.
+func (s *Server) CheckHandshake(check func(r *http.Request) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.check = check
+}
+
+// Requests returns the requests the server received so far, authentication requests excluded.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// serve answers the requests of a connection
+func (s *Server) serve(conn *websocket.Conn) {
+	var writeMu sync.Mutex
+	write := func(r Request, reply Reply) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(websocket.TextMessage, encodeReply(r.RequestID, reply))
+	}
+	pending := map[string]Request{} // pending holds the requests challenged for credentials
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		r, err := decodeRequest(msg)
+		if err != nil {
+			write(r, Error(StatusMalformedRequest, err.Error()))
+			continue
+		}
+
+		s.mu.Lock()
+		auth, username, password := s.auth, s.username, s.password
+		s.mu.Unlock()
+		if auth {
+			if r.Op == "authentication" {
+				original, ok := pending[r.RequestID]
+				delete(pending, r.RequestID)
+				if !ok || !validSASL(r, username, password) {
+					write(r, Error(StatusUnauthorized, "Username and/or password are incorrect"))
+					continue
+				}
+				r = original
+			} else {
+				pending[r.RequestID] = r
+				write(r, Reply{Code: StatusAuthenticate})
+				continue
+			}
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		replies, ok := s.match(r)
+		s.mu.Unlock()
+		if !ok {
+			replies = []Reply{Error(StatusScriptEvaluationError, fmt.Sprintf("gremtunetest: no handler for %s %s", r.Op, r.Script()))}
+			if r.Op == "close" {
+				replies = []Reply{NoContent()}
+			}
+		}
+		go func(r Request, replies []Reply) { // Replies are written concurrently, as Gremlin Server does
+			for _, reply := range replies {
+				time.Sleep(reply.Delay)
+				switch {
+				case reply.Drop:
+				case reply.Disconnect:
+					conn.Close()
+					return
+				default:
+					if write(r, reply) != nil {
+						return
+					}
+				}
+			}
+		}(r, replies)
+	}
+}
+
+// match returns the replies of the last handler matching the request. The server must be locked.
+func (s *Server) match(r Request) ([]Reply, bool) {
+	for i := len(s.handlers) - 1; i >= 0; i-- {
+		if s.handlers[i].match(r) {
+			return s.handlers[i].replies, true
+		}
+	}
+	return nil, false
+}
+
+// decodeRequest reads a request, its mime type header first. The request id is a g:UUID in GraphSON 2.0.
+func decodeRequest(msg []byte) (r Request, err error) {
+	if len(msg) == 0 || len(msg) < int(msg[0])+1 {
+		return r, fmt.Errorf("request without mime type")
+	}
+	r.MimeType = string(msg[1 : msg[0]+1])
+	var body struct {
+		RequestID json.RawMessage            `json:"requestId"`
+		Op        string                     `json:"op"`
+		Processor string                     `json:"processor"`
+		Args      map[string]json.RawMessage `json:"args"`
+	}
+	if err = json.Unmarshal(msg[msg[0]+1:], &body); err != nil {
+		return r, fmt.Errorf("request is not GraphSON: %v", err)
+	}
+	r.Op, r.Processor, r.Args = body.Op, body.Processor, body.Args
+	if json.Unmarshal(body.RequestID, &r.RequestID) != nil {
+		var typed struct {
+			Value string `json:"@value"`
+		}
+		json.Unmarshal(body.RequestID, &typed)
+		r.RequestID = typed.Value
+	}
+	return r, nil
+}
+
+// validSASL reports whether the authentication request carries the SASL PLAIN credentials
+func validSASL(r Request, username, password string) bool {
+	var sasl string
+	if json.Unmarshal(r.Args["sasl"], &sasl) != nil {
+		return false
+	}
+	b, err := base64.StdEncoding.DecodeString(sasl)
+	return err == nil && string(b) == "\x00"+username+"\x00"+password
+}
+
+// encodeReply writes the response frame of the reply
+func encodeReply(requestID string, reply Reply) []byte {
+	data := reply.Data
+	if data == "" {
+		data = "null"
+	}
+	attributes := reply.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	id, _ := json.Marshal(requestID)
+	status, _ := json.Marshal(map[string]interface{}{"code": reply.Code, "message": reply.Message, "attributes": attributes})
+	return []byte(fmt.Sprintf(`{"requestId":%s,"status":%s,"result":{"data":%s,"meta":{}}}`, id, status, data))
+}
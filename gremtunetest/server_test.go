@@ -0,0 +1,154 @@
+package gremtunetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schwartzmx/gremtune"
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+func dial(t *testing.T, s *Server, configs ...gremtune.DialerConfig) *gremtune.Client {
+	c, err := gremtune.Dial(gremtune.NewDialer(s.URL(), configs...), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+func decode(t *testing.T, resp []gremtune.Response) []interface{} {
+	var values []interface{}
+	for _, r := range resp {
+		v, err := r.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v.(graphson.List)...)
+	}
+	return values
+}
+
+func TestScript(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.On(Script("g.V().count()"), Success(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":3}]}`))
+	s.On(ScriptMatching(`^g\.V\(\)\.hasLabel`), Partial(`{"@type":"g:List","@value":["a"]}`), Success(`{"@type":"g:List","@value":["b"]}`))
+
+	c := dial(t, s)
+	defer c.Close()
+
+	resp, err := c.Execute("g.V().count()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := decode(t, resp); !reflect.DeepEqual(values, []interface{}{int64(3)}) {
+		t.Errorf("Expected a count of 3, got %v", values)
+	}
+
+	resp, err = c.ExecuteWithBindings("g.V().hasLabel(x)", map[string]string{"x": "person"}, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := decode(t, resp); !reflect.DeepEqual(values, []interface{}{"a", "b"}) {
+		t.Errorf("Expected the partial results, got %v", values)
+	}
+	requests := s.Requests()
+	var x string
+	if err = requests[1].Binding("x", &x); err != nil || x != "person" {
+		t.Errorf("Expected the binding to be received, got %q %v", x, err)
+	}
+
+	if _, err = c.Execute("g.E()"); err == nil {
+		t.Error("Expected an error for a request without handler")
+	}
+}
+
+func TestBytecode(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.On(Bytecode("V", "hasLabel", "count"), Success(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":1}]}`))
+
+	c := dial(t, s)
+	defer c.Close()
+
+	if _, err := c.Submit(traversal.G.V().HasLabel("person").Count()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrorsAndDelays(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.On(Script("timeout"), Error(StatusServerTimeout, "too slow"))
+	s.On(Script("dropped"), Dropped())
+	s.On(Script("delayed"), Delayed(Success(`["ok"]`), 20*time.Millisecond))
+
+	c := dial(t, s)
+	defer c.Close()
+
+	if _, err := c.Execute("timeout"); !gremtune.IsTimeout(err) {
+		t.Errorf("Expected a server timeout, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ExecuteContext(ctx, "dropped"); !gremtune.IsTimeout(err) {
+		t.Errorf("Expected the dropped reply to time out, got %v", err)
+	}
+	if _, err := c.Execute("delayed"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RequireAuth("user", "secret")
+	s.On(Any(), Success(`["ok"]`))
+
+	c := dial(t, s, gremtune.SetAuthentication("user", "secret"))
+	defer c.Close()
+	if _, err := c.Execute("g.V()"); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := dial(t, s, gremtune.SetAuthentication("user", "wrong"))
+	defer wrong.Close()
+	if _, err := wrong.Execute("g.V()"); !gremtune.IsUnauthorized(err) {
+		t.Errorf("Expected wrong credentials to be refused, got %v", err)
+	}
+}
+
+func TestCheckHandshake(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CheckHandshake(func(r *http.Request) error {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			return errors.New("missing api key")
+		}
+		return nil
+	})
+
+	if err := gremtune.NewDialer(s.URL()).Connect(); err == nil {
+		t.Error("Expected the handshake without the header to be refused")
+	}
+	if err := gremtune.NewDialer(s.URL(), gremtune.SetHeaders(http.Header{"X-Api-Key": {"secret"}})).Connect(); err != nil {
+		t.Errorf("Expected the handshake with the header to be accepted, got %v", err)
+	}
+}
+
+func TestDisconnected(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.On(Any(), Disconnected())
+
+	c := dial(t, s)
+	defer c.Close()
+	if _, err := c.Execute("g.V()"); err == nil {
+		t.Error("Expected the request to fail with the connection")
+	}
+}
@@ -0,0 +1,369 @@
+package gremtune
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// httpMaxIdleConns is the number of connections to the server kept alive between requests
+const httpMaxIdleConns = 16
+
+// HTTPDialer is a transport sending every request as a POST of a JSON body to the /gremlin endpoint of Gremlin
+// Server or Neptune, reusing kept-alive connections. It works through load balancers and where long-lived
+// websockets are impractical, such as AWS Lambda. Only scripts can be evaluated: requests of sessions and bytecode
+// fail with ErrInvalidRequestArguments, as do requests with arguments the endpoint does not read, such as the
+// evaluation timeout, batch size and user agent of RequestOptions. Typed bindings are sent as plain JSON, and the
+// results of a request, streamed ones included, come in a single response.
+type HTTPDialer struct {
+	dialerSettings
+	host      string
+	client    *http.Client
+	frames    chan []byte
+	ctx       context.Context // ctx is cancelled on Close, aborting the requests in flight
+	cancel    context.CancelFunc
+	quit      chan struct{}
+	closeOnce sync.Once
+	connected bool
+	disposed  bool
+	err       error // err is set when the dialer is misconfigured and returned on connect
+	mu        sync.RWMutex
+}
+
+// NewHTTPDialer returns an HTTP transport to use when connecting to Gremlin Server or Neptune. The host is the
+// http:// or https:// url of the server, its path defaults to /gremlin. The configs are those of NewDialer:
+// SetAuthentication sends the credentials with basic authentication, SetSigV4Auth signs every request, and
+// SetHeaders, SetHeaderProvider, SetTLSConfig, SetProxy, SetTimeout, SetSerializer and the retry policy apply
+// too. The settings of the websocket are ignored. Only the GraphSON serializers are supported.
+func NewHTTPDialer(host string, configs ...DialerConfig) *HTTPDialer {
+	settings := configuredSettings(configs)
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &HTTPDialer{
+		dialerSettings: settings,
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:               settings.proxy,
+			TLSClientConfig:     settings.tlsConfig,
+			TLSHandshakeTimeout: settings.timeout,
+			MaxIdleConns:        httpMaxIdleConns,
+			MaxIdleConnsPerHost: httpMaxIdleConns,
+			IdleConnTimeout:     90 * time.Second,
+		}},
+		frames: make(chan []byte, 16),
+		ctx:    ctx,
+		cancel: cancel,
+		quit:   make(chan struct{}),
+	}
+	h.host, h.err = endpoint(host, "http", "https")
+	if _, ok := h.serializer.(graphSONSerializer); !ok && h.err == nil {
+		h.err = errors.New("the HTTP transport only supports the GraphSON serializers")
+	}
+	return h
+}
+
+// Connect reports a misconfigured dialer, there is no connection to open
+func (h *HTTPDialer) Connect() error {
+	if h.err != nil {
+		return h.err
+	}
+	h.mu.Lock()
+	h.connected = true
+	h.mu.Unlock()
+	return nil
+}
+
+// IsConnected returns whether the dialer is ready to send requests
+func (h *HTTPDialer) IsConnected() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.connected
+}
+
+// IsDisposed returns whether the dialer is closed
+func (h *HTTPDialer) IsDisposed() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.disposed
+}
+
+// Auth returns the credentials of the dialer, which are sent with every request rather than on a challenge
+func (h *HTTPDialer) Auth() (username, password string) {
+	if h.auth == nil {
+		return "", ""
+	}
+	return h.auth.username, h.auth.password
+}
+
+// Done returns the channel closed once the dialer is closed
+func (h *HTTPDialer) Done() <-chan struct{} {
+	return h.quit
+}
+
+func (h *HTTPDialer) reconnectPolicy() *ReconnectPolicy {
+	return nil // Every request has a connection of its own
+}
+
+// httpRequest is the body of a POST to the /gremlin endpoint
+type httpRequest struct {
+	Gremlin  string          `json:"gremlin"`
+	Bindings json.RawMessage `json:"bindings,omitempty"`
+	Language string          `json:"language,omitempty"`
+	Aliases  json.RawMessage `json:"aliases,omitempty"`
+}
+
+// httpArgs are the arguments of a request the /gremlin endpoint reads, others cannot be sent over HTTP
+var httpArgs = map[string]bool{"gremlin": true, "bindings": true, "language": true, "aliases": true}
+
+// plainBindings unwraps the GraphSON types of typed bindings, as the /gremlin endpoint reads plain JSON
+func plainBindings(raw json.RawMessage) (json.RawMessage, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var bindings interface{}
+	if err := d.Decode(&bindings); err != nil {
+		return nil, errors.Wrap(err, "reading bindings")
+	}
+	plain, err := plainValue(bindings)
+	if err != nil {
+		return nil, errors.Wrap(err, "bindings")
+	}
+	return json.Marshal(plain)
+}
+
+// plainValue returns the value with the @type and @value envelopes of GraphSON removed. Maps become JSON objects,
+// their keys must be strings.
+func plainValue(v interface{}) (interface{}, error) {
+	var err error
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			if v[i], err = plainValue(v[i]); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		typ, typed := v["@type"].(string)
+		value, ok := v["@value"]
+		if !typed || !ok || len(v) != 2 {
+			for key := range v {
+				if v[key], err = plainValue(v[key]); err != nil {
+					return nil, err
+				}
+			}
+			return v, nil
+		}
+		if typ != "g:Map" {
+			return plainValue(value)
+		}
+		items, ok := value.([]interface{})
+		if !ok || len(items)%2 != 0 {
+			return nil, errors.New("malformed g:Map")
+		}
+		m := make(map[string]interface{}, len(items)/2)
+		for i := 0; i < len(items); i += 2 {
+			k, err := plainValue(items[i])
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, errors.Errorf("the HTTP transport only sends maps with string keys, got a %T key", k)
+			}
+			if m[key], err = plainValue(items[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+// Write posts the request, its response is read by Read once the server answered
+func (h *HTTPDialer) Write(msg []byte) error {
+	mimeType, body := splitFrame(msg)
+	var req struct {
+		RequestID json.RawMessage            `json:"requestId"`
+		Op        string                     `json:"op"`
+		Processor string                     `json:"processor"`
+		Args      map[string]json.RawMessage `json:"args"`
+	}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return errors.Wrap(err, "reading request")
+	}
+	ids := frameIDs(body)
+	id := ids[0]
+	if req.Op != "eval" || req.Processor != "" {
+		h.deliver(errorFrame(id, statusInvalidRequestArguments, "the HTTP transport only evaluates scripts outside sessions, got "+req.Op, nil))
+		return nil
+	}
+	for name, value := range req.Args {
+		if !httpArgs[name] && !(name == "rebindings" && string(value) == "{}") {
+			h.deliver(errorFrame(id, statusInvalidRequestArguments, "the HTTP transport cannot send the argument "+name, nil))
+			return nil
+		}
+	}
+	bindings, err := plainBindings(req.Args["bindings"])
+	if err != nil {
+		h.deliver(errorFrame(id, statusInvalidRequestArguments, err.Error(), nil))
+		return nil
+	}
+	post := httpRequest{Bindings: bindings, Aliases: req.Args["aliases"]}
+	json.Unmarshal(req.Args["gremlin"], &post.Gremlin)
+	json.Unmarshal(req.Args["language"], &post.Language)
+	payload, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	go func() {
+		h.deliver(h.post(id, mimeType, payload))
+	}()
+	return nil
+}
+
+// post sends the request to the server and returns the response frame to read for it
+func (h *HTTPDialer) post(id, mimeType string, payload []byte) []byte {
+	req, err := h.newRequest(mimeType, payload)
+	if err != nil {
+		return errorFrame(id, statusServerError, err.Error(), nil)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return errorFrame(id, statusServerError, err.Error(), nil)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body) // Read to the end so that the connection is reused
+	if err != nil {
+		return errorFrame(id, statusServerError, err.Error(), nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return httpErrorFrame(id, resp.StatusCode, body)
+	}
+	var r Response
+	if err = json.Unmarshal(body, &r); err != nil {
+		return errorFrame(id, statusServerSerializationError, "reading the response: "+err.Error(), nil)
+	}
+	r.RequestID = id // The server gives the response an id of its own
+	frame, _ := json.Marshal(r)
+	return frame
+}
+
+// newRequest builds the POST of the payload with the headers of the dialer, authenticated with its credentials
+func (h *HTTPDialer) newRequest(mimeType string, payload []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, h.host, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(h.ctx)
+	if req.Header, err = h.header(); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", mimeType)
+	if host := req.Header.Get("Host"); host != "" { // A Host header overrides the host sent to the server
+		req.Host = host
+	}
+	if h.auth != nil {
+		req.SetBasicAuth(h.auth.username, h.auth.password)
+	}
+	if h.signer != nil {
+		u := *req.URL
+		if req.Host != "" {
+			u.Host = req.Host
+		}
+		if err = h.signer.sign(http.MethodPost, &u, req.Header, hashHex(payload)); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// httpStatusCodes maps the HTTP status codes of failed requests to the status codes of Gremlin Server
+var httpStatusCodes = map[int]int{
+	http.StatusBadRequest:          statusMalformedRequest,
+	http.StatusUnauthorized:        statusUnauthorized,
+	http.StatusForbidden:           statusUnauthorized,
+	http.StatusRequestTimeout:      statusServerTimeout,
+	http.StatusGatewayTimeout:      statusServerTimeout,
+	http.StatusInternalServerError: statusServerError,
+}
+
+// httpErrorFrame returns the response frame of a failed request. Gremlin Server answers with a message and the
+// exceptions, which become the status attributes. Neptune answers with a code and a detailed message, the body is
+// kept as the message so that NeptuneCode reads the code.
+func httpErrorFrame(id string, httpStatus int, body []byte) []byte {
+	code, ok := httpStatusCodes[httpStatus]
+	if !ok {
+		code = statusServerError
+	}
+	var e map[string]interface{}
+	if json.Unmarshal(body, &e) != nil {
+		return errorFrame(id, code, string(body), nil)
+	}
+	if _, neptune := e["code"]; neptune {
+		return errorFrame(id, code, string(body), nil)
+	}
+	message, _ := e["message"].(string)
+	attributes := map[string]interface{}{}
+	for _, key := range []string{"exceptions", "stackTrace"} {
+		if v, ok := e[key]; ok {
+			attributes[key] = v
+		}
+	}
+	return errorFrame(id, code, message, attributes)
+}
+
+// errorFrame returns the response frame failing the request with the status code and message
+func errorFrame(id string, code int, message string, attributes map[string]interface{}) []byte {
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	frame, _ := json.Marshal(Response{
+		RequestID: id,
+		Status:    Status{Code: code, Message: message, Attributes: attributes},
+		Result:    Result{Data: json.RawMessage("null"), Meta: map[string]interface{}{}},
+	})
+	return frame
+}
+
+// deliver hands the response frame to Read, unless the dialer is closed
+func (h *HTTPDialer) deliver(frame []byte) {
+	select {
+	case h.frames <- frame:
+	case <-h.quit:
+	}
+}
+
+// Read waits for the response of the next request the server answered
+func (h *HTTPDialer) Read() (int, []byte, error) {
+	select {
+	case frame := <-h.frames:
+		return 1, frame, nil
+	case <-h.quit:
+		return 0, nil, ErrConnectionClosed
+	}
+}
+
+// Close aborts the requests in flight and closes the kept-alive connections. Closing a closed dialer does nothing.
+func (h *HTTPDialer) Close() error {
+	h.closeOnce.Do(func() {
+		h.cancel()
+		close(h.quit)
+		h.mu.Lock()
+		h.disposed = true
+		h.mu.Unlock()
+		h.client.CloseIdleConnections()
+	})
+	return nil
+}
+
+// Ping does nothing until the dialer is closed, connections are checked on every request
+func (h *HTTPDialer) Ping(errs chan error) {
+	<-h.quit
+}
@@ -0,0 +1,144 @@
+package gremtune
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestHTTPDialer(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/gremlin" {
+			t.Errorf("Expected a POST to /gremlin, got %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if accept := r.Header.Get("Accept"); accept != GraphSONv3.MimeType() {
+			t.Errorf("Expected to accept %s, got %s", GraphSONv3.MimeType(), accept)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var req httpRequest
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Error(err)
+		}
+		switch req.Gremlin {
+		case "g.V(x)":
+			var bindings map[string]string
+			json.Unmarshal(req.Bindings, &bindings)
+			w.Write([]byte(`{"requestId":"41d2e28a-20a4-4ab0-b379-d810dede3786","status":{"message":"","code":200,"attributes":{}},"result":{"data":["` + bindings["x"] + `"],"meta":{}}}`))
+		case "g.V().has('age', age)": // Answers with the bindings it read
+			w.Write([]byte(`{"requestId":"41d2e28a-20a4-4ab0-b379-d810dede3786","status":{"message":"","code":200,"attributes":{}},"result":{"data":[` + string(req.Bindings) + `],"meta":{}}}`))
+		case "g.throttled()":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"requestId":"a","code":"ThrottlingException","detailedMessage":"Too many requests"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"No such property","exceptions":["groovy.lang.MissingPropertyException"]}`))
+		}
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c, err := Dial(NewHTTPDialer(srv.URL, SetAuthentication("user", "secret")), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.ExecuteWithBindings("g.V(x)", map[string]string{"x": "1"}, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp) != 1 || string(resp[0].Result.Data) != `["1"]` {
+			t.Errorf("Expected the result of the binding, got %v", resp)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("Expected the connection to be kept alive, got %d connections", n)
+	}
+
+	resp, err := c.ExecuteWithTypedBindings("g.V().has('age', age)", map[string]interface{}{
+		"age": int32(29), "names": []string{"a"}, "props": map[string]interface{}{"weight": 0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"age":29,"names":["a"],"props":{"weight":0.5}}]`; len(resp) != 1 || string(resp[0].Result.Data) != expected {
+		t.Errorf("Expected the typed bindings to be sent as plain JSON %s, got %v", expected, resp)
+	}
+	_, err = c.ExecuteWithOptions("g.V()", RequestOptions{EvaluationTimeout: time.Second})
+	if !errors.Is(err, ErrInvalidRequestArguments) {
+		t.Errorf("Expected the evaluation timeout to be refused over HTTP, got %v", err)
+	}
+
+	_, err = c.Execute("g.throttled()")
+	if NeptuneCode(err) != NeptuneThrottling {
+		t.Errorf("Expected the Neptune code of the error, got %v", err)
+	}
+	_, err = c.Execute("g.missing()")
+	var re *ResponseError
+	if !errors.As(err, &re) || re.Code != statusServerError || strings.Join(re.Exceptions(), ",") != "groovy.lang.MissingPropertyException" {
+		t.Errorf("Expected a server error with the exceptions, got %v", err)
+	}
+	s, err := c.NewSession(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Execute("g.V()"); !errors.Is(err, ErrInvalidRequestArguments) {
+		t.Errorf("Expected sessions to be refused over HTTP, got %v", err)
+	}
+}
+
+func TestHTTPDialerConfig(t *testing.T) {
+	if err := NewHTTPDialer("ws://127.0.0.1:8182").Connect(); err == nil {
+		t.Errorf("Expected a ws:// url to be refused")
+	}
+	if err := NewHTTPDialer("http://127.0.0.1:8182", SetSerializer(GraphBinary)).Connect(); err == nil {
+		t.Errorf("Expected GraphBinary to be refused")
+	}
+	d := NewHTTPDialer("https://neptune:8182", SetSigV4Auth("us-east-1", StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}))
+	req, err := d.newRequest(GraphSONv3.MimeType(), []byte(`{"gremlin":"g.V()"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "https://neptune:8182/gremlin" {
+		t.Errorf("Expected the /gremlin path by default, got %s", req.URL)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") {
+		t.Errorf("Expected the request to be signed, got %q", auth)
+	}
+}
+
+func TestHTTPDialerClose(t *testing.T) {
+	d := NewHTTPDialer("http://127.0.0.1:8182")
+	if username, password := d.Auth(); username != "" || password != "" {
+		t.Errorf("Expected no credentials, got %q %q", username, password)
+	}
+	d.Close()
+	if err := d.Close(); err != nil {
+		t.Errorf("Expected closing twice to do nothing, got %v", err)
+	}
+	if !d.IsDisposed() {
+		t.Error("Expected the dialer to be disposed")
+	}
+	if _, _, err := d.Read(); err != ErrConnectionClosed {
+		t.Errorf("Expected %v once closed, got %v", ErrConnectionClosed, err)
+	}
+}
@@ -0,0 +1,377 @@
+// Package ogm maps vertices and edges to Go structs and back. Fields are mapped with the gremlin struct tag: the
+// name of the property followed by options, or no name and the id or label option for the id and the label of
+// the element:
+//
+//	type Person struct {
+//		ID     string   `gremlin:",id"`
+//		Label  string   `gremlin:",label"`
+//		Name   string   `gremlin:"name,single"`
+//		Emails []string `gremlin:"email,set"`
+//		Age    int      `gremlin:"age,omitempty"`
+//	}
+//
+// Fields without the tag are left alone. The single, list and set options set the cardinality the property is
+// written with, single being refused on slice fields, and omitempty leaves out zero values when writing.
+package ogm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// field is a struct field mapped to a property, the id or the label
+type field struct {
+	index       int
+	name        string
+	id          bool
+	label       bool
+	cardinality *traversal.Token
+	omitEmpty   bool
+}
+
+// fields caches the mapped fields of struct types
+var fields sync.Map
+
+// structFields returns the mapped fields of the struct type
+func structFields(t reflect.Type) ([]field, error) {
+	if cached, ok := fields.Load(t); ok {
+		return cached.([]field), nil
+	}
+	var mapped []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("gremlin")
+		if !ok || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, errors.Errorf("field %s of %s is tagged but not exported", sf.Name, t)
+		}
+		parts := strings.Split(tag, ",")
+		f := field{index: i, name: parts[0]}
+		for _, option := range parts[1:] {
+			switch option {
+			case "id":
+				f.id = true
+			case "label":
+				f.label = true
+			case "single":
+				f.cardinality = &traversal.Cardinality.Single
+			case "list":
+				f.cardinality = &traversal.Cardinality.List
+			case "set":
+				f.cardinality = &traversal.Cardinality.Set
+			case "omitempty":
+				f.omitEmpty = true
+			default:
+				return nil, errors.Errorf("unknown option %q of field %s of %s", option, sf.Name, t)
+			}
+		}
+		if f.name == "" && !f.id && !f.label {
+			return nil, errors.Errorf("field %s of %s has neither a property name nor the id or label option", sf.Name, t)
+		}
+		if f.cardinality != nil && *f.cardinality == traversal.Cardinality.Single && isMultiValued(sf.Type) {
+			return nil, errors.Errorf("slice field %s of %s cannot have the single option, each value would replace the previous one", sf.Name, t)
+		}
+		mapped = append(mapped, f)
+	}
+	fields.Store(t, mapped)
+	return mapped, nil
+}
+
+// isMultiValued reports whether a field of the type holds several values of a property, which slices other than
+// []byte do
+func isMultiValued(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+}
+
+// Unmarshal maps a decoded result into the struct, or the slice of structs, dst points to. Results can be a
+// graphson.Vertex, a graphson.Edge, or a graphson.Map such as the ones of valueMap(true) and elementMap(). A
+// graphson.List of them is mapped into a slice. Properties with several values, such as the ones valueMap returns
+// and multi-valued properties on Neptune, are mapped into slice fields, or into other fields when they hold a
+// single value.
+func Unmarshal(result interface{}, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("cannot unmarshal into %T, it must be a non-nil pointer", dst)
+	}
+	return unmarshal(result, rv.Elem())
+}
+
+func unmarshal(result interface{}, dst reflect.Value) error {
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return unmarshal(result, dst.Elem())
+	case reflect.Slice:
+		items, ok := collection(result)
+		if !ok {
+			return errors.Errorf("cannot unmarshal %T into %s", result, dst.Type())
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := unmarshal(item, slice.Index(i)); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case reflect.Struct:
+		return unmarshalElement(result, dst)
+	}
+	return errors.Errorf("cannot unmarshal into %s", dst.Type())
+}
+
+// unmarshalElement maps an element into a struct
+func unmarshalElement(result interface{}, dst reflect.Value) error {
+	var id, label interface{}
+	properties := map[string]interface{}{}
+	switch e := result.(type) {
+	case graphson.Vertex:
+		id, label = e.ID, e.Label
+		for key, props := range e.Properties {
+			properties[key] = props
+		}
+	case graphson.Edge:
+		id, label = e.ID, e.Label
+		for key, prop := range e.Properties {
+			properties[key] = prop
+		}
+	case graphson.Map:
+		for _, entry := range e {
+			switch key := entry.Key.(type) {
+			case graphson.T:
+				if key == "id" {
+					id = entry.Value
+				} else if key == "label" {
+					label = entry.Value
+				}
+			case string:
+				properties[key] = entry.Value
+			}
+		}
+	default:
+		return errors.Errorf("cannot unmarshal %T into %s", result, dst.Type())
+	}
+
+	mapped, err := structFields(dst.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range mapped {
+		fv := dst.Field(f.index)
+		var err error
+		switch {
+		case f.id && fv.Kind() == reflect.String && id != nil:
+			fv.SetString(fmt.Sprint(id)) // Ids of any type, such as the numbers of TinkerGraph
+		case f.id:
+			err = assign(id, fv)
+		case f.label:
+			err = assign(label, fv)
+		default:
+			value, ok := properties[f.name]
+			if !ok {
+				continue
+			}
+			err = assignProperty(value, fv)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "field %s", dst.Type().Field(f.index).Name)
+		}
+	}
+	return nil
+}
+
+// collection returns the items of a list or a set
+func collection(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case graphson.List:
+		return v, true
+	case graphson.Set:
+		return v, true
+	case []interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// propertyValues returns the values of a property, whatever form the result holds it in
+func propertyValues(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []graphson.VertexProperty:
+		values := make([]interface{}, len(v))
+		for i, vp := range v {
+			values[i] = vp.Value
+		}
+		return values
+	case graphson.VertexProperty:
+		return []interface{}{v.Value}
+	case graphson.Property:
+		return []interface{}{v.Value}
+	}
+	if items, ok := collection(v); ok {
+		values := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			values = append(values, propertyValues(item)...)
+		}
+		return values
+	}
+	return []interface{}{v}
+}
+
+// assignProperty sets the values of a property on a slice field, or its only value on any other field
+func assignProperty(v interface{}, dst reflect.Value) error {
+	values := propertyValues(v)
+	if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(dst.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := assign(value, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+		return nil
+	}
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		return assign(values[0], dst)
+	}
+	return errors.Errorf("cannot set %d values on a %s", len(values), dst.Type())
+}
+
+// assign sets a value on a field, converting numbers between types
+func assign(v interface{}, dst reflect.Value) error {
+	if v == nil {
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(v, dst.Elem())
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Type().AssignableTo(dst.Type()):
+		dst.Set(rv)
+		return nil
+	case isNumber(rv.Kind()) && isNumber(dst.Kind()):
+		if isUnsigned(dst.Kind()) && isNegative(rv) { // Converting back would give the negative number again
+			return errors.Errorf("%v does not fit in a %s", v, dst.Type())
+		}
+		converted := rv.Convert(dst.Type())
+		if !reflect.DeepEqual(converted.Convert(rv.Type()).Interface(), v) {
+			return errors.Errorf("%v does not fit in a %s", v, dst.Type())
+		}
+		dst.Set(converted)
+		return nil
+	}
+	return errors.Errorf("cannot set %T on a %s", v, dst.Type())
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+func isUnsigned(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+// isNegative reports whether the number is below zero
+func isNegative(v reflect.Value) bool {
+	switch {
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		return v.Int() < 0
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		return v.Float() < 0
+	}
+	return false
+}
+
+// AddV returns the traversal adding a vertex for the struct, labelled with its label field, or the name of its
+// type without one, and with its id when the id field is set.
+func AddV(v interface{}) (*traversal.Traversal, error) {
+	rv, mapped, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	label := rv.Type().Name()
+	for _, f := range mapped {
+		if fv := rv.Field(f.index); f.label && fv.Kind() == reflect.String && fv.String() != "" {
+			label = fv.String()
+		}
+	}
+	t := traversal.G.AddV(label)
+	for _, f := range mapped {
+		if fv := rv.Field(f.index); f.id && !fv.IsZero() {
+			t = t.Property(traversal.T.ID, fv.Interface())
+		}
+	}
+	return properties(t, rv, mapped), nil
+}
+
+// Properties appends the property steps setting the properties of the struct to the traversal, such as
+// traversal.G.V(id) to update a vertex. Slice fields set every value of the slice.
+func Properties(t *traversal.Traversal, v interface{}) (*traversal.Traversal, error) {
+	rv, mapped, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return properties(t, rv, mapped), nil
+}
+
+func properties(t *traversal.Traversal, rv reflect.Value, mapped []field) *traversal.Traversal {
+	property := func(f field, value interface{}) {
+		if f.cardinality != nil {
+			t = t.Property(*f.cardinality, f.name, value)
+		} else {
+			t = t.Property(f.name, value)
+		}
+	}
+	for _, f := range mapped {
+		fv := rv.Field(f.index)
+		if f.id || f.label || (f.omitEmpty && fv.IsZero()) {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if isMultiValued(fv.Type()) {
+			for i := 0; i < fv.Len(); i++ {
+				property(f, fv.Index(i).Interface())
+			}
+			continue
+		}
+		property(f, fv.Interface())
+	}
+	return t
+}
+
+// structValue returns the struct v holds or points to, with its mapped fields
+func structValue(v interface{}) (reflect.Value, []field, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, nil, errors.Errorf("cannot map %T, it must be a struct", v)
+	}
+	mapped, err := structFields(rv.Type())
+	return rv, mapped, err
+}
@@ -0,0 +1,142 @@
+package ogm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+type person struct {
+	ID       string    `gremlin:",id"`
+	Label    string    `gremlin:",label"`
+	Name     string    `gremlin:"name,single"`
+	Emails   []string  `gremlin:"email,set"`
+	Age      int       `gremlin:"age,omitempty"`
+	Joined   time.Time `gremlin:"joined,omitempty"`
+	Nickname *string   `gremlin:"nickname"`
+	Ignored  string
+}
+
+func TestUnmarshalValueMap(t *testing.T) {
+	result := graphson.Map{
+		{Key: graphson.T("id"), Value: int64(1)},
+		{Key: graphson.T("label"), Value: "person"},
+		{Key: "name", Value: graphson.List{"marko"}},
+		{Key: "email", Value: graphson.List{"marko@example.com", "m@example.com"}},
+		{Key: "age", Value: graphson.List{int32(29)}},
+		{Key: "nickname", Value: graphson.List{"mo"}},
+	}
+	var p person
+	if err := Unmarshal(result, &p); err != nil {
+		t.Fatal(err)
+	}
+	nickname := "mo"
+	expected := person{ID: "1", Label: "person", Name: "marko", Emails: []string{"marko@example.com", "m@example.com"}, Age: 29, Nickname: &nickname}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+}
+
+func TestUnmarshalVertices(t *testing.T) {
+	result := graphson.List{
+		graphson.Vertex{ID: "a", Label: "person", Properties: map[string][]graphson.VertexProperty{
+			"name":  {{Label: "name", Value: "vadas"}},
+			"email": {{Label: "email", Value: "v@example.com"}},
+		}},
+		graphson.Vertex{ID: "b", Label: "person", Properties: map[string][]graphson.VertexProperty{
+			"name": {{Label: "name", Value: "josh"}},
+		}},
+	}
+	var people []*person
+	if err := Unmarshal(result, &people); err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 || people[0].Name != "vadas" || people[1].ID != "b" || !reflect.DeepEqual(people[0].Emails, []string{"v@example.com"}) {
+		t.Errorf("Unexpected people %+v %+v", people[0], people[1])
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var p person
+	multi := graphson.Map{{Key: "name", Value: graphson.List{"a", "b"}}}
+	if err := Unmarshal(multi, &p); err == nil {
+		t.Error("Expected an error setting two values on a string")
+	}
+	overflow := graphson.Map{{Key: "age", Value: 29.5}}
+	if err := Unmarshal(overflow, &p); err == nil {
+		t.Error("Expected an error setting a fraction on an int")
+	}
+	var counter struct {
+		Count uint `gremlin:"count"`
+	}
+	if err := Unmarshal(graphson.Map{{Key: "count", Value: int64(-1)}}, &counter); err == nil {
+		t.Errorf("Expected an error setting a negative number on a uint, got %d", counter.Count)
+	}
+	if err := Unmarshal(graphson.Map{{Key: "count", Value: -1.0}}, &counter); err == nil {
+		t.Errorf("Expected an error setting a negative float on a uint, got %d", counter.Count)
+	}
+	if err := Unmarshal(graphson.Map{{Key: "count", Value: int64(3)}}, &counter); err != nil || counter.Count != 3 {
+		t.Errorf("Expected 3 to be set on a uint, got %d %v", counter.Count, err)
+	}
+	if err := Unmarshal(multi, p); err == nil {
+		t.Error("Expected an error unmarshaling into a struct that is not a pointer")
+	}
+}
+
+func TestAddV(t *testing.T) {
+	p := person{Label: "person", Name: "marko", Emails: []string{"a@example.com", "b@example.com"}}
+	tr, err := AddV(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := traversal.G.AddV("person").
+		Property(traversal.Cardinality.Single, "name", "marko").
+		Property(traversal.Cardinality.Set, "email", "a@example.com").
+		Property(traversal.Cardinality.Set, "email", "b@example.com")
+	if !reflect.DeepEqual(tr.Steps(), expected.Steps()) {
+		t.Errorf("Expected %v, got %v", expected.Steps(), tr.Steps())
+	}
+
+	p = person{ID: "x", Name: "josh", Age: 32}
+	tr, err = AddV(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = traversal.G.AddV("person").Property(traversal.T.ID, "x").
+		Property(traversal.Cardinality.Single, "name", "josh").Property("age", 32)
+	if !reflect.DeepEqual(tr.Steps(), expected.Steps()) {
+		t.Errorf("Expected %v, got %v", expected.Steps(), tr.Steps())
+	}
+}
+
+func TestProperties(t *testing.T) {
+	tr, err := Properties(traversal.G.V("x"), person{Name: "josh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := traversal.G.V("x").Property(traversal.Cardinality.Single, "name", "josh")
+	if !reflect.DeepEqual(tr.Steps(), expected.Steps()) {
+		t.Errorf("Expected %v, got %v", expected.Steps(), tr.Steps())
+	}
+	if _, err = Properties(traversal.G.V("x"), "josh"); err == nil {
+		t.Error("Expected an error mapping a string")
+	}
+}
+
+func TestSingleSlice(t *testing.T) {
+	var v struct {
+		Emails []string `gremlin:"email,single"`
+	}
+	if _, err := AddV(v); err == nil {
+		t.Error("Expected the single option to be refused on a slice field")
+	}
+	var b struct {
+		Avatar []byte `gremlin:"avatar,single"`
+	}
+	if _, err := AddV(b); err != nil {
+		t.Errorf("Expected the single option on a []byte field, got %v", err)
+	}
+}
@@ -1,9 +1,11 @@
 package gremtune
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
+
+	"github.com/schwartzmx/gremtune/traversal"
 )
 
 // Pool maintains a list of connections.
@@ -11,17 +13,23 @@ type Pool struct {
 	Dial        func() (*Client, error)
 	MaxActive   int
 	IdleTimeout time.Duration
+	RetryPolicy *RetryPolicy // RetryPolicy sends requests that failed for the moment again, on any connection
 	mu          sync.Mutex
 	idle        []*idleConnection
 	active      int
 	cond        *sync.Cond
 	closed      bool
+	retries     retryCounters
+	generation  int // generation changes on reset, connections of earlier generations are closed when returned
 }
 
 // PooledConnection represents a shared and reusable connection.
 type PooledConnection struct {
 	Pool   *Pool
 	Client *Client
+
+	onClose    func() // onClose is called once the connection is returned, for the cluster pool it was taken from
+	generation int
 }
 
 type idleConnection struct {
@@ -34,6 +42,16 @@ type idleConnection struct {
 // by dialing a new one if the pool does not currently have a maximum number
 // of active connections.
 func (p *Pool) Get() (*PooledConnection, error) {
+	return p.GetContext(context.Background())
+}
+
+// GetContext is like Get but stops waiting for a connection to become
+// available once ctx is done.
+func (p *Pool) GetContext(ctx context.Context) (*PooledConnection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Lock the pool to keep the kids out.
 	p.mu.Lock()
 
@@ -49,7 +67,7 @@ func (p *Pool) Get() (*PooledConnection, error) {
 			p.idle = append(p.idle[:0], p.idle[1:]...)
 			p.active++
 			p.mu.Unlock()
-			pc := &PooledConnection{Pool: p, Client: conn.pc.Client}
+			pc := &PooledConnection{Pool: p, Client: conn.pc.Client, generation: conn.pc.generation}
 			return pc, nil
 
 		}
@@ -58,6 +76,7 @@ func (p *Pool) Get() (*PooledConnection, error) {
 		if p.MaxActive == 0 || p.active < p.MaxActive {
 			p.active++
 			dial := p.Dial
+			generation := p.generation
 
 			// Unlock here so that any other connections that need to be
 			// dialed do not have to wait.
@@ -71,7 +90,7 @@ func (p *Pool) Get() (*PooledConnection, error) {
 				return nil, err
 			}
 
-			pc := &PooledConnection{Pool: p, Client: dc}
+			pc := &PooledConnection{Pool: p, Client: dc, generation: generation}
 			return pc, nil
 		}
 
@@ -80,14 +99,40 @@ func (p *Pool) Get() (*PooledConnection, error) {
 			p.cond = sync.NewCond(&p.mu)
 		}
 
+		p.wait(ctx)
+		if err := ctx.Err(); err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+	}
+}
+
+// wait blocks until a connection is released or ctx is done.
+// It is not threadsafe. The caller should manage locking the pool.
+func (p *Pool) wait(ctx context.Context) {
+	if ctx.Done() == nil {
 		p.cond.Wait()
+		return
 	}
+	woken := make(chan struct{})
+	defer close(woken)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Wake every waiter, the ones whose context is still alive go back to waiting
+			p.mu.Lock()
+			p.cond.Broadcast()
+			p.mu.Unlock()
+		case <-woken:
+		}
+	}()
+	p.cond.Wait()
 }
 
 // put pushes the supplied PooledConnection to the top of the idle slice to be reused.
 // It is not threadsafe. The caller should manage locking the pool.
 func (p *Pool) put(pc *PooledConnection) {
-	if p.closed {
+	if p.closed || pc.generation != p.generation {
 		pc.Client.Close()
 		return
 	}
@@ -140,6 +185,32 @@ func (p *Pool) first() *idleConnection {
 	return p.idle[0]
 }
 
+// idleLost reports whether one of the idle connections was lost or stops answering pings
+func (p *Pool) idleLost() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.idle {
+		// Errored is not read, the workers set it after disconnecting while the connection sits idle
+		if cl := c.pc.Client; cl.isDisconnected() || (cl.conn != nil && !cl.conn.IsConnected()) {
+			return true
+		}
+	}
+	return false
+}
+
+// reset closes the idle connections, and the active ones once they are returned, so that new ones are dialed
+// as when their host is down or no longer the writer.
+func (p *Pool) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.generation++
+	for _, c := range p.idle {
+		c.pc.Client.Close()
+	}
+	p.idle = nil
+}
+
 // Close closes the pool.
 func (p *Pool) Close() {
 	p.mu.Lock()
@@ -148,37 +219,112 @@ func (p *Pool) Close() {
 	for _, c := range p.idle {
 		c.pc.Client.Close()
 	}
+	p.idle = nil
 	p.closed = true
 }
 
 // ExecuteWithBindings formats a raw Gremlin query, sends it to Gremlin Server, and returns the result.
 func (p *Pool) ExecuteWithBindings(query string, bindings, rebindings map[string]string) (resp []Response, err error) {
-	pc, err := p.Get()
-	if err != nil {
-		fmt.Printf("Error aquiring connection from pool: %s", err)
-		return nil, err
-	}
-	defer pc.Close()
-	return pc.Client.ExecuteWithBindings(query, bindings, rebindings)
+	return p.ExecuteWithBindingsContext(context.Background(), query, bindings, rebindings)
+}
+
+// ExecuteWithBindingsContext is like ExecuteWithBindings but gives up waiting for a connection or for
+// Gremlin Server once ctx is done.
+func (p *Pool) ExecuteWithBindingsContext(ctx context.Context, query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return p.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithBindingsContext(ctx, query, bindings, rebindings)
+	})
 }
 
 // Execute grabs a connection from the pool, formats a raw Gremlin query, sends it to Gremlin Server, and returns the result.
 func (p *Pool) Execute(query string) (resp []Response, err error) {
-	pc, err := p.Get()
-	if err != nil {
-		fmt.Printf("Error aquiring connection from pool: %s", err)
-		return nil, err
+	return p.ExecuteContext(context.Background(), query)
+}
+
+// ExecuteContext is like Execute but gives up waiting for a connection or for Gremlin Server once ctx is done.
+func (p *Pool) ExecuteContext(ctx context.Context, query string) (resp []Response, err error) {
+	return p.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteContext(ctx, query)
+	})
+}
+
+// ExecuteWithTypedBindings grabs a connection from the pool, sends a raw Gremlin query to Gremlin Server with
+// bindings of any type, and returns the result.
+func (p *Pool) ExecuteWithTypedBindings(query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return p.ExecuteWithTypedBindingsContext(context.Background(), query, bindings)
+}
+
+// ExecuteWithTypedBindingsContext is like ExecuteWithTypedBindings but gives up waiting for a connection or for
+// Gremlin Server once ctx is done.
+func (p *Pool) ExecuteWithTypedBindingsContext(ctx context.Context, query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return p.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithTypedBindingsContext(ctx, query, bindings)
+	})
+}
+
+// ExecuteWithOptions grabs a connection from the pool, sends a raw Gremlin query to Gremlin Server with the
+// options, and returns the result.
+func (p *Pool) ExecuteWithOptions(query string, options RequestOptions) (resp []Response, err error) {
+	return p.ExecuteWithOptionsContext(context.Background(), query, options)
+}
+
+// ExecuteWithOptionsContext is like ExecuteWithOptions but gives up waiting for a connection or for Gremlin Server
+// once ctx is done.
+func (p *Pool) ExecuteWithOptionsContext(ctx context.Context, query string, options RequestOptions) (resp []Response, err error) {
+	return p.execute(ctx, options.Idempotent, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithOptionsContext(ctx, query, options)
+	})
+}
+
+// Submit grabs a connection from the pool, sends the traversal to Gremlin Server as bytecode, and returns the result.
+func (p *Pool) Submit(t *traversal.Traversal) (resp []Response, err error) {
+	return p.SubmitContext(context.Background(), t)
+}
+
+// SubmitContext is like Submit but gives up waiting for a connection or for Gremlin Server once ctx is done.
+func (p *Pool) SubmitContext(ctx context.Context, t *traversal.Traversal) (resp []Response, err error) {
+	return p.execute(ctx, false, func(c *Client) ([]Response, error) {
+		return c.SubmitContext(ctx, t)
+	})
+}
+
+// execute sends a request on a connection from the pool, and again on the connection available then following
+// the retry policy of the pool.
+func (p *Pool) execute(ctx context.Context, idempotent bool, send func(c *Client) ([]Response, error)) (resp []Response, err error) {
+	attempt := func() error {
+		pc, err := p.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer pc.Close()
+		resp, err = send(pc.Client)
+		if NeptuneCode(err) == NeptuneReadOnlyViolation { // The writer failed over, the new one must be dialed
+			p.reset()
+		}
+		return err
+	}
+	if p.RetryPolicy == nil {
+		err = attempt()
+	} else {
+		err = p.RetryPolicy.do(ctx, idempotent, &p.retries, attempt)
 	}
-	defer pc.Close()
-	return pc.Client.Execute(query)
+	return
+}
+
+// RetryStats returns the number of requests the retry policy of the pool sent again.
+func (p *Pool) RetryStats() RetryStats {
+	return p.retries.get()
 }
 
 // Close signals that the caller is finished with the connection and should be
 // returned to the pool for future use.
 func (pc *PooledConnection) Close() {
 	pc.Pool.mu.Lock()
-	defer pc.Pool.mu.Unlock()
-
 	pc.Pool.put(pc)
 	pc.Pool.release()
+	pc.Pool.mu.Unlock()
+
+	if pc.onClose != nil {
+		pc.onClose()
+	}
 }
@@ -1,6 +1,7 @@
 package gremtune
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -189,3 +190,30 @@ func TestGetAndDial(t *testing.T) {
 		t.Errorf("Expected 1 active connection, got %d", pool.active)
 	}
 }
+
+func TestGetContextDone(t *testing.T) {
+	pool := &Pool{MaxActive: 1, active: 1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	conn, err := pool.GetContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if conn != nil {
+		t.Error("Expected no connection")
+	}
+
+	// The pool is still usable after a waiter gave up
+	pool.Dial = func() (*Client, error) {
+		return &Client{}, nil
+	}
+	pool.mu.Lock()
+	pool.release()
+	pool.mu.Unlock()
+
+	if _, err = pool.GetContext(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,131 @@
+package gremtune
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConnectionClosed is returned to requests that were waiting on, or sent to, a connection which is gone for
+// good: it was closed, or it was lost and the client could not or would not reconnect.
+var ErrConnectionClosed = errors.New("connection to Gremlin Server closed")
+
+// ErrConnectionLost is returned to requests that were waiting on a connection that went down. The client
+// re-dials Gremlin Server in the background when it has a reconnect policy, so the request may be sent again.
+var ErrConnectionLost = errors.New("connection to Gremlin Server lost")
+
+// ReconnectPolicy controls how a lost connection to Gremlin Server is re-established.
+type ReconnectPolicy struct {
+	MaxAttempts     int           // MaxAttempts is the number of dials before giving up, 0 keeps trying until the client is closed
+	InitialInterval time.Duration // InitialInterval is the wait before the first dial
+	MaxInterval     time.Duration // MaxInterval caps the wait between dials
+	Multiplier      float64       // Multiplier grows the wait after every failed dial
+	Jitter          float64       // Jitter randomizes every wait by up to this fraction of it
+}
+
+// DefaultReconnectPolicy returns a policy which keeps re-dialing, waiting from half a second up to 30 seconds between dials.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+// backoff returns how long to wait before the given attempt, counting from 0.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	return exponentialBackoff(p.InitialInterval, p.MaxInterval, p.Multiplier, p.Jitter, attempt)
+}
+
+// exponentialBackoff returns the wait before the given attempt, counting from 0: the initial wait multiplied for
+// every attempt before, capped and randomized by up to the jitter fraction of it.
+func exponentialBackoff(initial, max time.Duration, multiplier, jitter float64, attempt int) time.Duration {
+	wait := float64(initial)
+	for i := 0; i < attempt && (max <= 0 || wait < float64(max)); i++ {
+		wait *= multiplier
+	}
+	if max > 0 && wait > float64(max) {
+		wait = float64(max)
+	}
+	if jitter > 0 {
+		wait += wait * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// reconnect re-dials Gremlin Server following the dialer's reconnect policy. Requests waiting on the lost
+// connection fail with ErrConnectionLost and the write worker holds new requests back until the connection is
+// back or the client gives up.
+func (c *Client) reconnect(quit <-chan struct{}) (err error) {
+	c.reconnecting.Lock()
+	defer c.reconnecting.Unlock()
+
+	c.failPending(ErrConnectionLost)
+
+	policy := c.reconnection
+	for attempt := 0; policy.MaxAttempts == 0 || attempt < policy.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-quit:
+			return errors.New("client closed while reconnecting")
+		}
+		if err = c.conn.Connect(); err == nil {
+			return
+		}
+	}
+	return errors.Wrapf(err, "giving up reconnecting after %d attempts", policy.MaxAttempts)
+}
+
+// canReconnect reports whether a lost connection should be re-dialed rather than reported.
+func (c *Client) canReconnect(quit <-chan struct{}) bool {
+	select {
+	case <-quit: // The client was closed on purpose
+		return false
+	case <-c.disconnected: // The client gave up reconnecting
+		return false
+	default:
+	}
+	return c.reconnection != nil
+}
+
+// disconnect marks the connection as gone for good and fails every request waiting on it with ErrConnectionClosed.
+func (c *Client) disconnect() {
+	c.disconnectOnce.Do(func() {
+		close(c.disconnected)
+	})
+	c.failPending(ErrConnectionClosed)
+}
+
+// isDisconnected reports whether the connection is gone for good.
+func (c *Client) isDisconnected() bool {
+	select {
+	case <-c.disconnected:
+		return true
+	default:
+		return false
+	}
+}
+
+// failPending delivers err to every request still waiting on a response.
+func (c *Client) failPending(err error) {
+	c.responseNotifier.Range(func(id, notifier interface{}) bool {
+		select {
+		case notifier.(chan error) <- err:
+		default: // The request already has its final response
+		}
+		if statusNotifier, ok := c.responseStatusNotifier.Load(id); ok {
+			select {
+			case statusNotifier.(chan int) <- statusServerError: // Wakes up async retrievers
+			default:
+			}
+		}
+		return true
+	})
+	// Responses for abandoned requests will never arrive now
+	c.abandoned.Range(func(id, _ interface{}) bool {
+		c.abandoned.Delete(id)
+		return true
+	})
+}
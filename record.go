@@ -0,0 +1,307 @@
+package gremtune
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Cassette holds the requests a Recorder recorded, each with the responses Gremlin Server sent for it. The ids of
+// requests and sessions, which are random, are replaced by placeholders so that a Replayer can match the requests
+// of another run. Only GraphSON requests and responses can be replayed.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its responses.
+type Interaction struct {
+	MimeType  string   `json:"mimeType"`
+	Request   string   `json:"request"`
+	Responses []string `json:"responses"`
+}
+
+// placeholderPattern matches the placeholders that stand in for the ids of requests and sessions in a cassette
+var placeholderPattern = regexp.MustCompile(`00000000-0000-0000-0000-\d{12}`)
+
+// frameIDs returns the ids in a request or response frame that change from run to run: the id of the request
+// first, then the session of the arguments. Missing ids are empty.
+func frameIDs(body string) []string {
+	var frame struct {
+		RequestID json.RawMessage            `json:"requestId"`
+		Args      map[string]json.RawMessage `json:"args"`
+	}
+	if json.Unmarshal([]byte(body), &frame) != nil {
+		return nil
+	}
+	id := func(raw json.RawMessage) string {
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			var typed typedValue // GraphSON 2.0 writes the request id as a g:UUID
+			json.Unmarshal(raw, &typed)
+			s, _ = typed.Value.(string)
+		}
+		return s
+	}
+	return []string{id(frame.RequestID), id(frame.Args["session"])}
+}
+
+// splitFrame splits a request into its mime type and its body
+func splitFrame(msg []byte) (mimeType, body string) {
+	if len(msg) == 0 || len(msg) < int(msg[0])+1 {
+		return "", string(msg)
+	}
+	return string(msg[1 : msg[0]+1]), string(msg[msg[0]+1:])
+}
+
+// Recorder is a dialer recording the requests the client sends on a WebSocket and the responses of Gremlin
+// Server into a cassette, saved when the client is closed. Replay it with a Replayer to run the same requests
+// without a server. Connect, Ping, Auth and Done are the ones of the WebSocket dialer.
+type Recorder struct {
+	*Ws
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+	ids      map[string]string // ids maps the ids of requests and sessions to their placeholders
+	latest   map[string]int    // latest maps the request ids to the index of their latest interaction
+}
+
+// NewRecorder returns a dialer recording the traffic of the WebSocket dialer to the cassette file at path.
+func NewRecorder(ws *Ws, path string) *Recorder {
+	return &Recorder{Ws: ws, path: path, ids: map[string]string{}, latest: map[string]int{}}
+}
+
+// placeholder returns the placeholder of the id. The recorder must be locked.
+func (r *Recorder) placeholder(id string) string {
+	p, ok := r.ids[id]
+	if !ok {
+		p = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(r.ids)+1)
+		r.ids[id] = p
+	}
+	return p
+}
+
+// normalize replaces the ids of the frame by their placeholders. The recorder must be locked.
+func (r *Recorder) normalize(body string) string {
+	for _, id := range frameIDs(body) {
+		if id != "" {
+			body = strings.Replace(body, id, r.placeholder(id), -1)
+		}
+	}
+	return body
+}
+
+// Write records the request and sends it on the websocket
+func (r *Recorder) Write(msg []byte) error {
+	r.mu.Lock()
+	mimeType, body := splitFrame(msg)
+	ids := frameIDs(body)
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{MimeType: mimeType, Request: r.normalize(body)})
+	if len(ids) > 0 && ids[0] != "" {
+		r.latest[ids[0]] = len(r.cassette.Interactions) - 1
+	}
+	r.mu.Unlock()
+	return r.Ws.Write(msg)
+}
+
+// Read waits for the next message on the websocket and records it with the request it answers
+func (r *Recorder) Read() (msgType int, msg []byte, err error) {
+	msgType, msg, err = r.Ws.Read()
+	if err != nil || msg == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body := string(msg)
+	i := len(r.cassette.Interactions) - 1 // Frames of unknown requests go with the latest one
+	if ids := frameIDs(body); len(ids) > 0 {
+		if latest, ok := r.latest[ids[0]]; ok {
+			i = latest
+		}
+	}
+	if i >= 0 {
+		r.cassette.Interactions[i].Responses = append(r.cassette.Interactions[i].Responses, r.normalize(body))
+	}
+	return
+}
+
+// Close closes the websocket and saves the cassette
+func (r *Recorder) Close() error {
+	err := r.Ws.Close()
+	if saveErr := r.Save(); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// Save writes the cassette recorded so far to its file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(ioutil.WriteFile(r.path, b, 0644), "saving cassette")
+}
+
+// Replayer is a dialer answering the requests of the client with the responses recorded in a cassette, without
+// any server. A request is answered by the first recorded request that is the same but for its ids, and a
+// request that was not recorded fails with an invalid request arguments error.
+type Replayer struct {
+	dialerSettings
+	cassette  Cassette
+	mu        sync.Mutex
+	used      []bool
+	frames    chan []byte
+	quit      chan struct{}
+	closeOnce sync.Once
+	connected bool
+	disposed  bool
+}
+
+// NewReplayer returns a dialer replaying the cassette file at path. The configs are those of NewDialer, such as
+// SetSerializer and SetAuthentication, which must match the ones the cassette was recorded with.
+func NewReplayer(path string, configs ...DialerConfig) (*Replayer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading cassette")
+	}
+	var cassette Cassette
+	if err = json.Unmarshal(b, &cassette); err != nil {
+		return nil, errors.Wrap(err, "reading cassette")
+	}
+	return &Replayer{
+		dialerSettings: configuredSettings(configs),
+		cassette:       cassette,
+		used:           make([]bool, len(cassette.Interactions)),
+		frames:         make(chan []byte, 64),
+		quit:           make(chan struct{}),
+	}, nil
+}
+
+// Connect marks the replayer connected, there is no server to dial
+func (r *Replayer) Connect() error {
+	r.mu.Lock()
+	r.connected = true
+	r.mu.Unlock()
+	return nil
+}
+
+// IsConnected returns whether the replayer is connected
+func (r *Replayer) IsConnected() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connected
+}
+
+// IsDisposed returns whether the replayer is closed
+func (r *Replayer) IsDisposed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.disposed
+}
+
+// Auth returns the credentials of the replayer, answering the challenges recorded in the cassette
+func (r *Replayer) Auth() (username, password string) {
+	if r.auth == nil {
+		return "", ""
+	}
+	return r.auth.username, r.auth.password
+}
+
+// Done returns the channel closed once the replayer is closed
+func (r *Replayer) Done() <-chan struct{} {
+	return r.quit
+}
+
+func (r *Replayer) reconnectPolicy() *ReconnectPolicy {
+	return nil // There is no connection to lose
+}
+
+// Write answers the request with the responses recorded for it, or with an error if it was not recorded
+func (r *Replayer) Write(msg []byte) error {
+	mimeType, body := splitFrame(msg)
+	stripped := placeholderPattern.ReplaceAllString(strip(body), "")
+	ids := frameIDs(body)
+
+	r.mu.Lock()
+	var responses []string
+	matched := false
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.MimeType != mimeType || placeholderPattern.ReplaceAllString(interaction.Request, "") != stripped {
+			continue
+		}
+		r.used[i] = true
+		matched = true
+		// Put the ids of this run in place of the placeholders
+		var replacements []string
+		for j, p := range frameIDs(interaction.Request) {
+			if p != "" && j < len(ids) {
+				replacements = append(replacements, p, ids[j])
+			}
+		}
+		replacer := strings.NewReplacer(replacements...)
+		for _, resp := range interaction.Responses {
+			responses = append(responses, replacer.Replace(resp))
+		}
+		break
+	}
+	r.mu.Unlock()
+
+	if !matched {
+		id := ""
+		if len(ids) > 0 {
+			id = ids[0]
+		}
+		responses = []string{string(errorFrame(id, statusInvalidRequestArguments, "no recorded request matches "+body, nil))}
+	}
+	for _, resp := range responses {
+		select {
+		case r.frames <- []byte(resp):
+		case <-r.quit:
+			return ErrConnectionClosed
+		}
+	}
+	return nil
+}
+
+// strip removes the ids of the frame
+func strip(body string) string {
+	for _, id := range frameIDs(body) {
+		if id != "" {
+			body = strings.Replace(body, id, "", -1)
+		}
+	}
+	return body
+}
+
+// Read waits for the next recorded response
+func (r *Replayer) Read() (int, []byte, error) {
+	select {
+	case msg := <-r.frames:
+		return 1, msg, nil
+	case <-r.quit:
+		return 0, nil, ErrConnectionClosed
+	}
+}
+
+// Close stops the replayer and the workers of the client. Closing a closed replayer does nothing.
+func (r *Replayer) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+		r.mu.Lock()
+		r.disposed = true
+		r.mu.Unlock()
+	})
+	return nil
+}
+
+// Ping does nothing until the replayer is closed, there is no connection to keep alive
+func (r *Replayer) Ping(errs chan error) {
+	<-r.quit
+}
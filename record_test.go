@@ -0,0 +1,113 @@
+package gremtune
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+// recordTestSession runs a plain request and a session on the client, returning their results along with the ids
+// of the requests they answer
+func recordTestSession(t *testing.T, c *Client) []interface{} {
+	var results []interface{}
+	collect := func(resp []Response, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data []string
+		json.Unmarshal(resp[0].Result.Data, &data)
+		results = append(results, append(data, resp[0].RequestID))
+	}
+	collect(c.ExecuteWithBindings("g.V(x)", map[string]string{"x": "1"}, map[string]string{}))
+	s, err := c.NewSession(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(s.Execute("g.addV('person')"))
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return append(results, s.ID())
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	for _, script := range []string{"g.V(x)", "g.addV('person')"} {
+		srv.On(gremtunetest.Script(script), gremtunetest.Success(`["`+script+`"]`))
+	}
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder := NewRecorder(NewDialer(srv.URL()), cassette)
+	c, err := Dial(recorder, make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := recordTestSession(t, &c)
+	c.Close()
+	srv.Close()
+
+	b, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), recorded[2].(string)) {
+		t.Errorf("Expected the session id to be replaced by a placeholder in the cassette")
+	}
+
+	replayer, err := NewReplayer(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = Dial(replayer, make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	replayed := recordTestSession(t, &c)
+	for i := 0; i < 2; i++ {
+		r, p := recorded[i].([]string), replayed[i].([]string)
+		if r[0] != p[0] {
+			t.Errorf("Expected request %d to replay %s, got %s", i, r[0], p[0])
+		}
+		if r[1] == p[1] {
+			t.Errorf("Expected request %d to be answered with the id of the replayed request", i)
+		}
+	}
+
+	if _, err = c.Execute("g.E()"); !errors.Is(err, ErrInvalidRequestArguments) {
+		t.Errorf("Expected a request that was not recorded to fail with invalid request arguments, got %v", err)
+	}
+	if _, err = c.ExecuteWithBindings("g.V(x)", map[string]string{"x": "1"}, map[string]string{}); !errors.Is(err, ErrInvalidRequestArguments) {
+		t.Errorf("Expected a recorded request to be replayed once, got %v", err)
+	}
+}
+
+func TestReplayerClose(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	if err := ioutil.WriteFile(cassette, []byte(`{"interactions":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReplayer(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username, password := r.Auth(); username != "" || password != "" {
+		t.Errorf("Expected no credentials, got %q %q", username, password)
+	}
+	r.Close()
+	if err = r.Close(); err != nil {
+		t.Errorf("Expected closing twice to do nothing, got %v", err)
+	}
+	if !r.IsDisposed() {
+		t.Error("Expected the replayer to be disposed")
+	}
+	if _, _, err = r.Read(); err != ErrConnectionClosed {
+		t.Errorf("Expected %v once closed, got %v", ErrConnectionClosed, err)
+	}
+}
@@ -1,12 +1,19 @@
 package gremtune
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/traversal"
 )
 
+// closeRequestTimeout bounds how long a close request may wait to be written before it is dropped
+const closeRequestTimeout = 5 * time.Second
+
+// requester is implemented by every kind of request the client sends. prepare must be called before the others.
 type requester interface {
 	prepare() error
 	getID() string
@@ -21,6 +28,50 @@ type request struct {
 	Args      map[string]interface{} `json:"args"`
 }
 
+// RequestOptions are the optional arguments of a request. Zero fields are left to the defaults of Gremlin Server.
+type RequestOptions struct {
+	// EvaluationTimeout overrides the time the server allows for evaluating the request. It is sent as both the
+	// evaluationTimeout of TinkerPop 3.4 and later and the scriptEvaluationTimeout of earlier versions.
+	EvaluationTimeout time.Duration
+	// BatchSize is the number of results the server sends per response, 64 by default on Neptune.
+	BatchSize int
+	// Aliases rebinds the names of graphs and traversal sources on the server, such as g to another source.
+	Aliases map[string]string
+	// UserAgent identifies the application to the server.
+	UserAgent string
+	// Args holds any other arguments of the request. They cannot override the arguments already set.
+	Args map[string]interface{}
+	// Idempotent declares that evaluating the request twice does no harm, so that it is sent again after the
+	// connection is lost while waiting on it. It is not sent to the server.
+	Idempotent bool
+	// ReadOnly declares that the request does not change the graph, so that a Router sends it to the readers. It
+	// is not sent to the server.
+	ReadOnly bool
+}
+
+// apply sets the options on the arguments of a request
+func (o *RequestOptions) apply(args map[string]interface{}) {
+	if o.EvaluationTimeout > 0 {
+		ms := int64(o.EvaluationTimeout / time.Millisecond)
+		args["evaluationTimeout"] = ms
+		args["scriptEvaluationTimeout"] = ms
+	}
+	if o.BatchSize > 0 {
+		args["batchSize"] = o.BatchSize
+	}
+	if o.Aliases != nil {
+		args["aliases"] = o.Aliases
+	}
+	if o.UserAgent != "" {
+		args["userAgent"] = o.UserAgent
+	}
+	for key, value := range o.Args {
+		if _, ok := args[key]; !ok {
+			args[key] = value
+		}
+	}
+}
+
 // prepareRequest packages a query and binding into the format that Gremlin Server accepts
 func prepareRequest(query string) (req request, id string, err error) {
 	var uuID uuid.UUID
@@ -57,6 +108,62 @@ func prepareRequestWithBindings(query string, bindings, rebindings map[string]st
 	return
 }
 
+// prepareRequestWithTypedBindings packages a query and bindings of any type into the format that Gremlin Server
+// accepts, the serializer writes the bindings with their types
+func prepareRequestWithTypedBindings(query string, bindings map[string]interface{}) (req request, id string, err error) {
+	req, id, err = prepareRequest(query)
+	req.Args["bindings"] = bindings
+	return
+}
+
+// prepareBytecodeRequest packages a traversal into a bytecode request, which the traversal op processor runs on the
+// traversal source the traversal was spawned from without compiling a script
+func prepareBytecodeRequest(t *traversal.Traversal) (req request, id string, err error) {
+	if t.Source() == "__" {
+		err = errors.New("an anonymous traversal cannot be submitted")
+		return
+	}
+	var uuID uuid.UUID
+	uuID, err = uuid.NewV4()
+	if err != nil {
+		return
+	}
+	id = uuID.String()
+
+	req.RequestID = id
+	req.Op = "bytecode"
+	req.Processor = "traversal"
+
+	req.Args = make(map[string]interface{})
+	req.Args["gremlin"] = t
+	req.Args["aliases"] = map[string]string{"g": t.Source()}
+
+	return
+}
+
+// prepareSessionCloseRequest creates a request asking Gremlin Server to close the session, rolling back whatever
+// the session left uncommitted. A forced close does not wait for the script being evaluated to complete.
+func prepareSessionCloseRequest(session string, force bool) (req request, id string, err error) {
+	var uuID uuid.UUID
+	uuID, err = uuid.NewV4()
+	if err != nil {
+		return
+	}
+	id = uuID.String()
+
+	req.RequestID = id
+	req.Op = "close"
+	req.Processor = "session"
+
+	req.Args = make(map[string]interface{})
+	req.Args["session"] = session
+	if force {
+		req.Args["force"] = true
+	}
+
+	return
+}
+
 //prepareAuthRequest creates a ws request for Gremlin Server
 func prepareAuthRequest(requestID string, username string, password string) (req request, err error) {
 	req.RequestID = requestID
@@ -78,14 +185,96 @@ func prepareAuthRequest(requestID string, username string, password string) (req
 	return
 }
 
-// formatMessage takes a request type and formats it into being able to be delivered to Gremlin Server
-func packageRequest(req request) (msg []byte, err error) {
-	j, err := json.Marshal(req) // Formats request into byte format
+// evalRequest evaluates a Gremlin script, optionally with bindings and in a session.
+type evalRequest struct {
+	query             string
+	bindings          *map[string]string
+	rebindings        *map[string]string
+	typedBindings     map[string]interface{} // typedBindings are bindings of any type, sent instead of bindings
+	session           string // session is the id of the session to evaluate the script in, if any
+	manageTransaction bool
+	options           *RequestOptions
+	req               request
+	id                string
+}
+
+func (r *evalRequest) prepare() (err error) {
+	if r.typedBindings != nil {
+		r.req, r.id, err = prepareRequestWithTypedBindings(r.query, r.typedBindings)
+	} else if r.bindings != nil && r.rebindings != nil {
+		r.req, r.id, err = prepareRequestWithBindings(r.query, *r.bindings, *r.rebindings)
+	} else {
+		r.req, r.id, err = prepareRequest(r.query)
+	}
+	if err == nil && r.session != "" {
+		r.req.Processor = "session"
+		r.req.Args["session"] = r.session
+		r.req.Args["manageTransaction"] = r.manageTransaction
+	}
+	if err == nil && r.options != nil {
+		r.options.apply(r.req.Args)
+	}
+	return
+}
+
+func (r *evalRequest) getID() string {
+	return r.id
+}
+
+func (r *evalRequest) getRequest() request {
+	return r.req
+}
+
+// bytecodeRequest runs a traversal sent as bytecode.
+type bytecodeRequest struct {
+	traversal *traversal.Traversal
+	req       request
+	id        string
+}
+
+func (r *bytecodeRequest) prepare() (err error) {
+	r.req, r.id, err = prepareBytecodeRequest(r.traversal)
+	return
+}
+
+func (r *bytecodeRequest) getID() string {
+	return r.id
+}
+
+func (r *bytecodeRequest) getRequest() request {
+	return r.req
+}
+
+// sessionCloseRequest closes a session.
+type sessionCloseRequest struct {
+	session string
+	force   bool
+	req     request
+	id      string
+}
+
+func (r *sessionCloseRequest) prepare() (err error) {
+	r.req, r.id, err = prepareSessionCloseRequest(r.session, r.force)
+	return
+}
+
+func (r *sessionCloseRequest) getID() string {
+	return r.id
+}
+
+func (r *sessionCloseRequest) getRequest() request {
+	return r.req
+}
+
+// packageRequest formats the request with the serializer into being able to be delivered to Gremlin Server: the
+// length of the mime type, the mime type and the serialized request
+func packageRequest(req request, s Serializer) (msg []byte, err error) {
+	j, err := s.encodeRequest(req) // Formats request into byte format
 	if err != nil {
 		return
 	}
-	mimeType := []byte("application/vnd.gremlin-v3.0+json")
-	msg = append([]byte{0x21}, mimeType...) //0x21 is the fixed length of mimeType in hex
+	mimeType := []byte(s.MimeType())
+	msg = append([]byte{byte(len(mimeType))}, mimeType...)
 	msg = append(msg, j...)
 
 	return
@@ -95,3 +284,26 @@ func packageRequest(req request) (msg []byte, err error) {
 func (c *Client) dispatchRequest(msg []byte) {
 	c.requests <- msg
 }
+
+// dispatchRequestContext sends the request for writing like dispatchRequest, but abandons it if ctx is done before
+// the write worker is ready to take it.
+func (c *Client) dispatchRequestContext(ctx context.Context, id string, msg []byte) error {
+	if err := ctx.Err(); err != nil {
+		c.abandonRequest(id)
+		return err
+	}
+	select {
+	case c.requests <- msg:
+		return nil
+	case <-ctx.Done():
+		c.abandonRequest(id)
+		return ctx.Err()
+	}
+}
+
+// cancelRequest abandons the request, the responses arriving later for it are discarded. Gremlin Server cannot
+// stop evaluating a single sessionless request, so nothing is sent to it: cancelling a request of a session closes
+// the session instead, which is up to the Session.
+func (c *Client) cancelRequest(id string) {
+	c.abandonRequest(id)
+}
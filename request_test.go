@@ -1,9 +1,15 @@
 package gremtune
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+	"github.com/schwartzmx/gremtune/traversal"
 )
 
 // TestRequestPreparation tests the ability to package a query and a set of bindings into a request struct for further manipulation
@@ -46,7 +52,7 @@ func TestRequestPackaging(t *testing.T) {
 		},
 	}
 
-	msg, err := packageRequest(testRequest)
+	msg, err := packageRequest(testRequest, GraphSONv3)
 	if err != nil {
 		t.Error(err)
 	}
@@ -83,7 +89,7 @@ func TestRequestDispatch(t *testing.T) {
 		},
 	}
 	c := newClient()
-	msg, err := packageRequest(testRequest)
+	msg, err := packageRequest(testRequest, GraphSONv3)
 	if err != nil {
 		t.Error(err)
 	}
@@ -100,7 +106,7 @@ func TestAuthRequestDispatch(t *testing.T) {
 	testRequest, _ := prepareAuthRequest(id, "test", "root")
 
 	c := newClient()
-	msg, err := packageRequest(testRequest)
+	msg, err := packageRequest(testRequest, GraphSONv3)
 	if err != nil {
 		t.Error(err)
 	}
@@ -125,3 +131,134 @@ func TestAuthRequestPreparation(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// TestRequestDispatchContextDone tests that dispatching gives up once the context is done when nobody takes the request
+func TestRequestDispatchContextDone(t *testing.T) {
+	c := newClient()
+	c.requests = make(chan []byte) // Nobody is writing
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	id := "1d6d02bd-8e56-421d-9438-3bd6d0079ff1"
+	c.responseNotifier.Store(id, make(chan error, 1))
+	err := c.dispatchRequestContext(ctx, id, []byte("msg"))
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if _, ok := c.responseNotifier.Load(id); ok {
+		t.Error("Expected response notifier to be removed")
+	}
+}
+
+// TestCancelRequest tests that cancelling a sessionless request abandons it without sending anything to Gremlin
+// Server, which cannot stop it
+func TestCancelRequest(t *testing.T) {
+	c := newClient()
+	id := "1d6d02bd-8e56-421d-9438-3bd6d0079ff1"
+	c.responseNotifier.Store(id, make(chan error, 1))
+	c.responseStatusNotifier.Store(id, make(chan int, 1))
+
+	c.cancelRequest(id)
+
+	if _, ok := c.abandoned.Load(id); !ok {
+		t.Error("Expected request to be abandoned")
+	}
+	select {
+	case msg := <-c.requests:
+		t.Errorf("Expected nothing to be sent, got %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestBytecodeRequestPreparation tests packaging a traversal into a bytecode request for the traversal op processor
+func TestBytecodeRequestPreparation(t *testing.T) {
+	req, id, err := prepareBytecodeRequest(traversal.G.V().HasLabel("person"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := packageRequest(req, GraphSONv3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		RequestID string                     `json:"requestId"`
+		Op        string                     `json:"op"`
+		Processor string                     `json:"processor"`
+		Args      map[string]json.RawMessage `json:"args"`
+	}
+	if err := json.Unmarshal(msg[msg[0]+1:], &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RequestID != id || body.Op != "bytecode" || body.Processor != "traversal" {
+		t.Errorf("Unexpected request %+v", body)
+	}
+	if expected := `{"@type":"g:Bytecode","@value":{"step":[["V"],["hasLabel","person"]]}}`; string(body.Args["gremlin"]) != expected {
+		t.Errorf("Expected bytecode %s, got %s", expected, body.Args["gremlin"])
+	}
+	if expected := `{"g":"g"}`; string(body.Args["aliases"]) != expected {
+		t.Errorf("Expected aliases %s, got %s", expected, body.Args["aliases"])
+	}
+
+	if _, _, err := prepareBytecodeRequest(traversal.Anon().Out()); err == nil {
+		t.Error("Expected an error submitting an anonymous traversal")
+	}
+	if _, err := packageRequest(req, GraphSONv1); err == nil {
+		t.Error("Expected an error serializing bytecode as GraphSON 1.0")
+	}
+}
+
+// TestSubmit tests that a submitted traversal is answered like a script
+func TestSubmit(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Bytecode("V", "count"), gremtunetest.Success(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":6}]}`))
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	resp, err := c.Submit(traversal.G.V().Count())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := srv.Requests()[0]; req.Op != "bytecode" || req.Processor != "traversal" {
+		t.Errorf("Expected a bytecode request, got %s %s", req.Op, req.Processor)
+	}
+	v, err := resp[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, graphson.List{int64(6)}) {
+		t.Errorf("Expected a count of 6, got %#v", v)
+	}
+}
+
+func TestRequestOptions(t *testing.T) {
+	r := &evalRequest{query: "g.V()", options: &RequestOptions{
+		EvaluationTimeout: 2 * time.Second,
+		BatchSize:         500,
+		Aliases:           map[string]string{"g": "social"},
+		UserAgent:         "exporter/1.0",
+		Args:              map[string]interface{}{"materializeProperties": "tokens", "gremlin": "g.E()"},
+	}}
+	if err := r.prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"language":                "gremlin-groovy",
+		"gremlin":                 "g.V()",
+		"evaluationTimeout":       int64(2000),
+		"scriptEvaluationTimeout": int64(2000),
+		"batchSize":               500,
+		"aliases":                 map[string]string{"g": "social"},
+		"userAgent":               "exporter/1.0",
+		"materializeProperties":   "tokens",
+	}
+	if !reflect.DeepEqual(r.getRequest().Args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, r.getRequest().Args)
+	}
+}
@@ -1,6 +1,7 @@
 package gremtune
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -31,6 +32,8 @@ type Result struct {
 	// Query Response Data
 	Data json.RawMessage        `json:"data"`
 	Meta map[string]interface{} `json:"meta"`
+
+	decoded interface{} // decoded holds the data of serializers that are not JSON, which leave Data empty
 }
 
 // AsyncResponse structs holds the entire response from requests to the gremlin server
@@ -44,6 +47,8 @@ type Response struct {
 	RequestID string `json:"requestId"`
 	Status    Status `json:"status"`
 	Result    Result `json:"result"`
+
+	serializer Serializer // serializer read the response, its data is decoded in the same format
 }
 
 // ToString returns a string representation of the Response struct
@@ -51,12 +56,25 @@ func (r Response) ToString() string {
 	return fmt.Sprintf("Response \nRequestID: %v, \nStatus: {%#v}, \nResult: {%#v}\n", r.RequestID, r.Status, r.Result)
 }
 
+// Decode decodes the data of the response into Go values with the serializer the response was read with, see
+// graphson.UnmarshalVersion. The data of responses to eval requests is a graphson.List of the results.
+func (r Response) Decode() (interface{}, error) {
+	if r.serializer == nil { // The response was not read from Gremlin Server
+		return GraphSONv3.decodeResult(r.Result)
+	}
+	return r.serializer.decodeResult(r.Result)
+}
+
 func (c *Client) handleResponse(msg []byte) (err error) {
-	resp, err := marshalResponse(msg)
+	resp, err := c.serializer.decodeResponse(msg)
 
 	if resp.Status.Code == statusAuthenticate { //Server request authentication
 		return c.authenticate(resp.RequestID)
 	}
+	if rs, ok := c.streams.Load(resp.RequestID); ok { // Result sets take their responses as they are consumed
+		rs.(*ResultSet).deliver(resp, err)
+		return
+	}
 
 	c.saveResponse(resp, err)
 	return
@@ -77,6 +95,9 @@ func marshalResponse(msg []byte) (resp Response, err error) {
 func (c *Client) saveResponse(resp Response, err error) {
 	c.Lock()
 	defer c.Unlock()
+	if c.discardAbandoned(resp) {
+		return
+	}
 	var container []interface{}
 	existingData, ok := c.results.Load(resp.RequestID) // Retrieve old data container (for requests with multiple responses)
 	if ok {
@@ -94,15 +115,66 @@ func (c *Client) saveResponse(resp Response, err error) {
 	if resp.Status.Code != statusPartialContent {
 		respNotifier.(chan error) <- err
 	}
+	// The requester may have given up while the response was being stored
+	c.discardAbandoned(resp)
+}
+
+// discardAbandoned drops everything held for the response's request if the requester has abandoned it, and
+// reports whether it did so.
+func (c *Client) discardAbandoned(resp Response) bool {
+	if _, ok := c.abandoned.Load(resp.RequestID); !ok {
+		return false
+	}
+	if resp.Status.Code != statusPartialContent { // No more responses will arrive for this request
+		c.abandoned.Delete(resp.RequestID)
+	}
+	c.responseNotifier.Delete(resp.RequestID)
+	c.responseStatusNotifier.Delete(resp.RequestID)
+	c.deleteResponse(resp.RequestID)
+	return true
+}
+
+// abandonRequest is used when the requester stops waiting before the final response arrived. Everything held for
+// the request is dropped and responses still coming for it are discarded by saveResponse.
+func (c *Client) abandonRequest(id string) {
+	c.abandoned.Store(id, struct{}{})
+	c.responseNotifier.Delete(id)
+	c.responseStatusNotifier.Delete(id)
+	c.deleteResponse(id)
 }
 
 // retrieveResponseAsync retrieves the response saved by saveResponse and send the retrieved reponse to the channel .
-func (c *Client) retrieveResponseAsync(id string, responseChannel chan AsyncResponse) {
+func (c *Client) retrieveResponseAsync(ctx context.Context, id string, responseChannel chan AsyncResponse) {
 	var responseProcessedIndex int
 	responseNotifier, _ := c.responseNotifier.Load(id)
 	responseStatusNotifier, _ := c.responseStatusNotifier.Load(id)
-	for status := range responseStatusNotifier.(chan int) {
-		_ = status
+	defer close(responseChannel)
+
+	// send delivers a response to the caller unless ctx is done first
+	send := func(asyncResponse AsyncResponse) bool {
+		select {
+		case responseChannel <- asyncResponse:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// cancel reports the context error to the caller and stops waiting for the remaining responses
+	cancel := func() {
+		c.cancelRequest(id)
+		select {
+		case responseChannel <- AsyncResponse{ErrorMessage: ctx.Err().Error()}:
+		default: // The caller is not reading, the closed channel will have to do
+		}
+	}
+
+	for {
+		select {
+		case <-responseStatusNotifier.(chan int):
+		case <-ctx.Done():
+			cancel()
+			return
+		}
 		if dataI, ok := c.results.Load(id); ok {
 			d := dataI.([]interface{})
 			// Only retrieve all but one from the partial responses saved in results Map that are not sent to responseChannel
@@ -111,15 +183,24 @@ func (c *Client) retrieveResponseAsync(id string, responseChannel chan AsyncResp
 				var asyncResponse AsyncResponse = AsyncResponse{}
 				asyncResponse.Response = d[i].(Response)
 				// Send the Partial response object to the responseChannel
-				responseChannel <- asyncResponse
+				if !send(asyncResponse) {
+					cancel()
+					return
+				}
 			}
 		}
 		//Checks to see If there was an Error or full response has been provided by Neptune
 		if len(responseNotifier.(chan error)) > 0 {
 			//Checks to see If there was an Error or will get nil when final reponse has been provided by Neptune
 			err := <-responseNotifier.(chan error)
+			var d []interface{}
 			if dataI, ok := c.results.Load(id); ok {
-				d := dataI.([]interface{})
+				d = dataI.([]interface{})
+			}
+			if err != nil && responseProcessedIndex == len(d) {
+				// The request failed without a response of its own, e.g. the connection was closed
+				send(AsyncResponse{ErrorMessage: err.Error()})
+			} else {
 				// Retrieve all the partial responses that are not sent to responseChannel
 				for i := responseProcessedIndex; i < len(d); i++ {
 					responseProcessedIndex++
@@ -130,27 +211,31 @@ func (c *Client) retrieveResponseAsync(id string, responseChannel chan AsyncResp
 						asyncResponse.ErrorMessage = err.Error()
 					}
 					// Send the Partial response object to the responseChannel
-					responseChannel <- asyncResponse
+					if !send(asyncResponse) {
+						break
+					}
 				}
 			}
 			// All the Partial response object including the final one has been sent to the responseChannel
 			break
 		}
 	}
-	// All the Partial response object including the final one has been sent to the responseChannel so closing responseStatusNotifier, responseNotifier, responseChannel and removing all the reponse stored
-	close(responseStatusNotifier.(chan int))
-	close(responseNotifier.(chan error))
+	// All the Partial response object including the final one has been sent to the responseChannel so removing all the reponse stored
 	c.responseNotifier.Delete(id)
 	c.responseStatusNotifier.Delete(id)
 	c.deleteResponse(id)
-	close(responseChannel)
 }
 
-// retrieveResponse retrieves the response saved by saveResponse.
-func (c *Client) retrieveResponse(id string) (data []Response, err error) {
+// retrieveResponse retrieves the response saved by saveResponse. If ctx is done before the final response
+// arrives the request is cancelled and the context error is returned.
+func (c *Client) retrieveResponse(ctx context.Context, id string) (data []Response, err error) {
 	resp, _ := c.responseNotifier.Load(id)
-	responseStatusNotifier, _ := c.responseStatusNotifier.Load(id)
-	err = <-resp.(chan error)
+	select {
+	case err = <-resp.(chan error):
+	case <-ctx.Done():
+		c.cancelRequest(id)
+		return nil, ctx.Err()
+	}
 	if err == nil {
 		if dataI, ok := c.results.Load(id); ok {
 			d := dataI.([]interface{})
@@ -158,13 +243,11 @@ func (c *Client) retrieveResponse(id string) (data []Response, err error) {
 			for i := range d {
 				data[i] = d[i].(Response)
 			}
-			close(resp.(chan error))
-			close(responseStatusNotifier.(chan int))
-			c.responseNotifier.Delete(id)
-			c.responseStatusNotifier.Delete(id)
-			c.deleteResponse(id)
 		}
 	}
+	c.responseNotifier.Delete(id)
+	c.responseStatusNotifier.Delete(id)
+	c.deleteResponse(id)
 	return
 }
 
@@ -174,29 +257,11 @@ func (c *Client) deleteResponse(id string) {
 	return
 }
 
-// responseDetectError detects any possible errors in responses from Gremlin Server and generates an error for each code
+// responseDetectError detects any possible errors in responses from Gremlin Server and generates a ResponseError for each code
 func (r *Response) detectError() (err error) {
 	switch r.Status.Code {
 	case statusSuccess, statusNoContent, statusPartialContent:
-		break
-	case statusUnauthorized:
-		err = fmt.Errorf("UNAUTHORIZED - Response Message: %s", r.Status.Message)
-	case statusAuthenticate:
-		err = fmt.Errorf("AUTHENTICATE - Response Message: %s", r.Status.Message)
-	case statusMalformedRequest:
-		err = fmt.Errorf("MALFORMED REQUEST - Response Message: %s", r.Status.Message)
-	case statusInvalidRequestArguments:
-		err = fmt.Errorf("INVALID REQUEST ARGUMENTS - Response Message: %s", r.Status.Message)
-	case statusServerError:
-		err = fmt.Errorf("SERVER ERROR - Response Message: %s", r.Status.Message)
-	case statusScriptEvaluationError:
-		err = fmt.Errorf("SCRIPT EVALUATION ERROR - Response Message: %s", r.Status.Message)
-	case statusServerTimeout:
-		err = fmt.Errorf("SERVER TIMEOUT - Response Message: %s", r.Status.Message)
-	case statusServerSerializationError:
-		err = fmt.Errorf("SERVER SERIALIZATION ERROR - Response Message: %s", r.Status.Message)
-	default:
-		err = fmt.Errorf("UNKNOWN ERROR - Response Message: %s", r.Status.Message)
+		return nil
 	}
-	return
+	return &ResponseError{Code: r.Status.Code, Message: r.Status.Message, Attributes: r.Status.Attributes, RequestID: r.RequestID}
 }
@@ -1,9 +1,13 @@
 package gremtune
 
 import (
+	"context"
 	"log"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/schwartzmx/gremtune/graphson"
 )
 
 /*
@@ -68,7 +72,7 @@ func TestResponseHandling(t *testing.T) {
 	var expected []Response
 	expected = append(expected, dummySuccessfulResponseMarshalled)
 
-	r, _ := c.retrieveResponse(dummySuccessfulResponseMarshalled.RequestID)
+	r, _ := c.retrieveResponse(context.Background(), dummySuccessfulResponseMarshalled.RequestID)
 	if reflect.TypeOf(expected).String() != reflect.TypeOf(r).String() {
 		t.Error("Expected data type does not match actual.")
 	}
@@ -86,7 +90,7 @@ func TestResponseAuthHandling(t *testing.T) {
 		return
 	}
 
-	sampleAuthRequest, err := packageRequest(req)
+	sampleAuthRequest, err := packageRequest(req, GraphSONv3)
 	if err != nil {
 		log.Println(err)
 		return
@@ -103,7 +107,7 @@ func TestResponseAuthHandling(t *testing.T) {
 	var expectedSuccessful []Response
 	expectedSuccessful = append(expectedSuccessful, dummySuccessfulResponseMarshalled)
 
-	r, _ := c.retrieveResponse(dummySuccessfulResponseMarshalled.RequestID)
+	r, _ := c.retrieveResponse(context.Background(), dummySuccessfulResponseMarshalled.RequestID)
 	if reflect.TypeOf(expectedSuccessful).String() != reflect.TypeOf(r).String() {
 		t.Error("Expected data type does not match actual.")
 	}
@@ -163,7 +167,7 @@ func TestResponseRetrieval(t *testing.T) {
 	c.saveResponse(dummyPartialResponse1Marshalled, nil)
 	c.saveResponse(dummyPartialResponse2Marshalled, nil)
 
-	resp, _ := c.retrieveResponse(dummyPartialResponse1Marshalled.RequestID)
+	resp, _ := c.retrieveResponse(context.Background(), dummyPartialResponse1Marshalled.RequestID)
 
 	var expected []Response
 	expected = append(expected, dummyPartialResponse1Marshalled)
@@ -234,3 +238,71 @@ func TestResponseErrorDetection(t *testing.T) {
 		}
 	}
 }
+
+// TestResponseRetrievalContextDone tests that a requester stops waiting once its context is done and that the request is cleaned up
+func TestResponseRetrievalContextDone(t *testing.T) {
+	c := newClient()
+	id := dummySuccessfulResponseMarshalled.RequestID
+	c.responseNotifier.Store(id, make(chan error, 1))
+	c.responseStatusNotifier.Store(id, make(chan int, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := c.retrieveResponse(ctx, id)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if _, ok := c.responseNotifier.Load(id); ok {
+		t.Error("Expected response notifier to be removed")
+	}
+	if _, ok := c.responseStatusNotifier.Load(id); ok {
+		t.Error("Expected response status notifier to be removed")
+	}
+
+	// A response arriving after the requester gave up is discarded
+	c.saveResponse(dummySuccessfulResponseMarshalled, nil)
+	if _, ok := c.results.Load(id); ok {
+		t.Error("Expected late response to be discarded")
+	}
+	if _, ok := c.abandoned.Load(id); ok {
+		t.Error("Expected abandoned request to be forgotten after its final response")
+	}
+}
+
+// TestResponseRetrievalAsyncContextDone tests that async retrieval reports the context error and closes the channel
+func TestResponseRetrievalAsyncContextDone(t *testing.T) {
+	c := newClient()
+	id := dummyPartialResponse1Marshalled.RequestID
+	c.responseNotifier.Store(id, make(chan error, 1))
+	c.responseStatusNotifier.Store(id, make(chan int, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	responseChannel := make(chan AsyncResponse, 2)
+	go c.retrieveResponseAsync(ctx, id, responseChannel)
+	c.saveResponse(dummyPartialResponse1Marshalled, nil)
+	cancel()
+
+	var last AsyncResponse
+	for r := range responseChannel {
+		last = r
+	}
+	if last.ErrorMessage != context.Canceled.Error() {
+		t.Errorf("Expected last response to carry %q, got %q", context.Canceled.Error(), last.ErrorMessage)
+	}
+	if _, ok := c.results.Load(id); ok {
+		t.Error("Expected results to be removed")
+	}
+}
+
+// TestResponseDecode tests decoding the GraphSON data of a response
+func TestResponseDecode(t *testing.T) {
+	resp := Response{Result: Result{Data: []byte(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":64}]}`)}}
+	v, err := resp.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, graphson.List{int64(64)}) {
+		t.Errorf("Unexpected decoded data %#v", v)
+	}
+}
@@ -0,0 +1,221 @@
+package gremtune
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/graphson"
+)
+
+// resultSetBuffer is the number of responses a result set holds before the client stops reading from the connection
+const resultSetBuffer = 2
+
+// streamedResponse is a response delivered to a result set with the error it carries
+type streamedResponse struct {
+	resp Response
+	err  error
+}
+
+// ResultSet iterates over the results of a request one at a time, as the partial responses of Gremlin Server
+// arrive, instead of holding all of them in memory:
+//
+//	rs, err := g.Stream(ctx, "g.V().hasLabel('person')")
+//	if err != nil {
+//		return err
+//	}
+//	defer rs.Close()
+//	for rs.Next(ctx) {
+//		v := rs.Value()
+//	}
+//	return rs.Err()
+//
+// Only a few responses are held for the result set. Once they are not consumed the client stops reading from the
+// connection, which makes Gremlin Server wait, and other requests on the same connection wait along. A result set
+// must be closed if it is not read to the end.
+type ResultSet struct {
+	client    *Client
+	id        string
+	responses chan streamedResponse
+	done      chan struct{} // done is closed once the result set no longer takes responses
+	closeOnce sync.Once
+	release   func() // release returns the connection of a pooled result set to its pool
+	items     []interface{}
+	value     interface{}
+	err       error
+	finished  bool // finished is set once the final response was consumed
+}
+
+// stream sends the request and returns the result set its responses are delivered to.
+func (c *Client) stream(ctx context.Context, r requester) (*ResultSet, error) {
+	if c.conn.IsDisposed() {
+		return nil, errors.New("you cannot write on disposed connection")
+	}
+	if err := r.prepare(); err != nil {
+		return nil, err
+	}
+	rs := &ResultSet{
+		client:    c,
+		id:        r.getID(),
+		responses: make(chan streamedResponse, resultSetBuffer),
+		done:      make(chan struct{}),
+	}
+	c.streams.Store(rs.id, rs) // Registered before sending so that no response can miss it
+	if err := c.sendRequest(ctx, r); err != nil {
+		rs.stop()
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Stream sends a raw Gremlin query to Gremlin Server and returns a result set iterating over its results. ctx
+// bounds sending the query, the result set is read with the context given to Next.
+func (c *Client) Stream(ctx context.Context, query string) (*ResultSet, error) {
+	return c.StreamWithOptions(ctx, query, RequestOptions{})
+}
+
+// StreamWithOptions is like Stream but sends the query with the options, such as the batch size of the responses.
+func (c *Client) StreamWithOptions(ctx context.Context, query string, options RequestOptions) (*ResultSet, error) {
+	rs, err := c.stream(ctx, &evalRequest{query: query, options: &options})
+	return rs, errors.Wrapf(err, "query: %s", query)
+}
+
+// Stream grabs a connection from the pool and streams the results of a raw Gremlin query on it, see
+// Client.Stream. The connection is kept by the result set until it is read to the end or closed.
+func (p *Pool) Stream(ctx context.Context, query string) (*ResultSet, error) {
+	return p.StreamWithOptions(ctx, query, RequestOptions{})
+}
+
+// StreamWithOptions is like Stream but sends the query with the options.
+func (p *Pool) StreamWithOptions(ctx context.Context, query string, options RequestOptions) (*ResultSet, error) {
+	pc, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := pc.Client.StreamWithOptions(ctx, query, options)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	rs.release = pc.Close
+	return rs, nil
+}
+
+// deliver hands a response over to the result set, waiting for room unless the result set or the connection is
+// closed first.
+func (rs *ResultSet) deliver(resp Response, err error) {
+	select {
+	case rs.responses <- streamedResponse{resp: resp, err: err}:
+	case <-rs.done:
+	case <-rs.client.disconnected:
+	}
+}
+
+// Next advances to the next result, waiting for the next response if needed. It returns false once the results
+// are exhausted, the request failed or ctx is done, Err tells which.
+func (rs *ResultSet) Next(ctx context.Context) bool {
+	if rs.err != nil {
+		return false
+	}
+	for len(rs.items) == 0 {
+		if rs.finished {
+			rs.value = nil
+			return false
+		}
+		select {
+		case streamed := <-rs.responses:
+			rs.receive(streamed)
+			continue
+		default:
+		}
+		notifier, _ := rs.client.responseNotifier.Load(rs.id)
+		failed, _ := notifier.(chan error) // Nil once the result set is stopped, which blocks forever
+		select {
+		case streamed := <-rs.responses:
+			rs.receive(streamed)
+		case err := <-failed: // The connection went away, taking the request with it
+			rs.finished = true
+			rs.fail(err)
+		case <-ctx.Done():
+			rs.fail(ctx.Err())
+		}
+		if rs.err != nil {
+			return false
+		}
+	}
+	rs.value, rs.items = rs.items[0], rs.items[1:]
+	return true
+}
+
+// receive takes the results of a response
+func (rs *ResultSet) receive(streamed streamedResponse) {
+	if streamed.resp.Status.Code != statusPartialContent {
+		rs.finished = true
+		rs.stop()
+	}
+	if streamed.err != nil {
+		rs.fail(streamed.err)
+		return
+	}
+	data, err := streamed.resp.Decode()
+	if err != nil {
+		rs.fail(err)
+		return
+	}
+	if list, ok := data.(graphson.List); ok {
+		rs.items = list
+	} else if data != nil {
+		rs.items = []interface{}{data}
+	}
+}
+
+// fail stops the result set with the error
+func (rs *ResultSet) fail(err error) {
+	rs.err = err
+	rs.value = nil
+	rs.Close()
+}
+
+// Value returns the current result, decoded like Response.Decode decodes each item.
+func (rs *ResultSet) Value() interface{} {
+	return rs.value
+}
+
+// Err returns the error that stopped the result set, nil if it was read to the end.
+func (rs *ResultSet) Err() error {
+	return rs.err
+}
+
+// Close stops the result set. If the results are not exhausted the responses still to come are discarded.
+// Closing a closed result set does nothing.
+func (rs *ResultSet) Close() error {
+	if !rs.finished && !rs.isStopped() {
+		rs.client.cancelRequest(rs.id)
+	}
+	rs.stop()
+	rs.items = nil
+	rs.finished = true
+	return nil
+}
+
+func (rs *ResultSet) isStopped() bool {
+	select {
+	case <-rs.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop unregisters the result set from the client and returns its connection to the pool, if any.
+func (rs *ResultSet) stop() {
+	rs.closeOnce.Do(func() {
+		close(rs.done)
+		rs.client.streams.Delete(rs.id)
+		rs.client.responseNotifier.Delete(rs.id)
+		rs.client.responseStatusNotifier.Delete(rs.id)
+		if rs.release != nil {
+			rs.release()
+		}
+	})
+}
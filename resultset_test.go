@@ -0,0 +1,117 @@
+package gremtune
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+// TestResultSet tests that a result set yields the results of every partial response in order
+func TestResultSet(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(),
+		gremtunetest.Partial(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":1},{"@type":"g:Int64","@value":2}]}`),
+		gremtunetest.Partial(`{"@type":"g:List","@value":[]}`),
+		gremtunetest.Success(`{"@type":"g:List","@value":[{"@type":"g:Int64","@value":3}]}`))
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rs, err := c.Stream(context.Background(), "g.V().id()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	var values []interface{}
+	for rs.Next(context.Background()) {
+		values = append(values, rs.Value())
+	}
+	if err = rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{int64(1), int64(2), int64(3)}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if rs.Next(context.Background()) {
+		t.Error("Expected an exhausted result set to stay exhausted")
+	}
+}
+
+// TestResultSetClose tests that closing a result set before its end cancels the request and lets the client read
+// the responses of other requests again
+func TestResultSetClose(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	var partials []gremtunetest.Reply
+	for i := 0; i < 20; i++ { // More than the result set holds
+		partials = append(partials, gremtunetest.Partial(fmt.Sprintf(`{"@type":"g:List","@value":[%d]}`, i)))
+	}
+	srv.On(gremtunetest.Script("g.V()"), partials...)
+	srv.On(gremtunetest.Script("g.V().count()"), gremtunetest.Success(`{"@type":"g:List","@value":["ok"]}`))
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rs, err := c.Stream(context.Background(), "g.V()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.Next(context.Background()) {
+		t.Fatal(rs.Err())
+	}
+	time.Sleep(50 * time.Millisecond) // Let the responses pile up
+	rs.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err = c.ExecuteContext(ctx, "g.V().count()"); err != nil {
+		t.Errorf("Expected the client to read responses again, got %v", err)
+	}
+	for _, req := range srv.Requests() {
+		if req.Op == "close" {
+			t.Errorf("Expected nothing to be sent for the closed result set, got %s %s", req.Op, req.Args)
+		}
+	}
+	if rs.Next(context.Background()) {
+		t.Error("Expected a closed result set to yield nothing")
+	}
+}
+
+func TestResultSetError(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Partial(`{"@type":"g:List","@value":[1]}`), gremtunetest.Error(statusServerTimeout, ""))
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rs, err := c.Stream(context.Background(), "g.V()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	count := 0
+	for rs.Next(context.Background()) {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Expected the result before the error, got %d results", count)
+	}
+	if rs.Err() == nil {
+		t.Error("Expected the timeout to stop the result set")
+	}
+}
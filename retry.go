@@ -0,0 +1,162 @@
+package gremtune
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy controls how requests that failed for the moment are sent again. Set it on the dialer with
+// SetRetryPolicy for a client, or on Pool.RetryPolicy for a pool, but not both as the retries would multiply.
+// Requests of sessions and result sets are never sent again, as they cannot be replayed on their own.
+type RetryPolicy struct {
+	MaxAttempts     int           // MaxAttempts is the number of times a request is sent at most, the first time included
+	InitialInterval time.Duration // InitialInterval is the wait before the first retry
+	MaxInterval     time.Duration // MaxInterval caps the wait between retries
+	Multiplier      float64       // Multiplier grows the wait after every retry
+	Jitter          float64       // Jitter randomizes every wait by up to this fraction of it
+	// Retryable decides whether the failed request is sent again, knowing whether it was declared idempotent with
+	// RequestOptions.Idempotent. DefaultRetryable is used when it is nil.
+	Retryable func(err error, idempotent bool) bool
+}
+
+// DefaultRetryPolicy returns a policy which sends a request up to 5 times, waiting from 100 milliseconds up to
+// 5 seconds between attempts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.5,
+	}
+}
+
+// Neptune error codes, which Neptune reports in the status message of failed requests, see NeptuneCode.
+const (
+	NeptuneConcurrentModification = "ConcurrentModificationException"
+	NeptuneReadOnlyViolation      = "ReadOnlyViolationException"
+	NeptuneThrottling             = "ThrottlingException"
+	NeptuneTooManyRequests        = "TooManyRequestsException"
+	NeptuneMemoryLimitExceeded    = "MemoryLimitExceededException"
+	NeptuneQueryLimitExceeded     = "QueryLimitExceededException"
+	NeptuneTimeLimitExceeded      = "TimeLimitExceededException"
+	NeptuneConstraintViolation    = "ConstraintViolationException"
+)
+
+// retryableNeptuneCodes are the Neptune errors of requests that were rejected or rolled back as a whole, which are
+// safe to send again whether idempotent or not. A read-only violation means the writer failed over to an instance
+// that was a reader, which takes some time.
+var retryableNeptuneCodes = map[string]bool{
+	NeptuneConcurrentModification: true,
+	NeptuneReadOnlyViolation:      true,
+	NeptuneThrottling:             true,
+	NeptuneTooManyRequests:        true,
+}
+
+// limitNeptuneCodes are the Neptune errors of requests that ran out of memory or queue space. The same request
+// usually runs out again, so it is only sent again when declared idempotent.
+var limitNeptuneCodes = map[string]bool{
+	NeptuneMemoryLimitExceeded: true,
+	NeptuneQueryLimitExceeded:  true,
+}
+
+// NeptuneCode returns the Neptune error code of the error, such as NeptuneConcurrentModification, or an empty
+// string if err is not a Neptune error.
+func NeptuneCode(err error) string {
+	var re *ResponseError
+	if !errors.As(err, &re) {
+		return ""
+	}
+	var message struct {
+		Code string `json:"code"`
+	}
+	if json.Unmarshal([]byte(re.Message), &message) != nil {
+		return ""
+	}
+	return message.Code
+}
+
+// DefaultRetryable retries the requests Gremlin Server or Neptune rejected or rolled back as a whole, such as on
+// a concurrent modification or throttling, and the idempotent requests lost with the connection, which the server
+// may have evaluated already, or that exceeded the memory or query limits of Neptune.
+func DefaultRetryable(err error, idempotent bool) bool {
+	if errors.Is(err, ErrConnectionLost) || limitNeptuneCodes[NeptuneCode(err)] {
+		return idempotent
+	}
+	return isTransient(err)
+}
+
+// isTransient reports whether the server rejected or rolled back the request as a whole for the moment
+func isTransient(err error) bool {
+	if retryableNeptuneCodes[NeptuneCode(err)] {
+		return true
+	}
+	var re *ResponseError
+	if !errors.As(err, &re) {
+		return false
+	}
+	for _, exception := range re.Exceptions() {
+		if retryableExceptions[exception] {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry, counting from 0.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	return exponentialBackoff(p.InitialInterval, p.MaxInterval, p.Multiplier, p.Jitter, retry)
+}
+
+// do calls send until it succeeds, its error is not retryable, the attempts are exhausted or ctx is done.
+func (p *RetryPolicy) do(ctx context.Context, idempotent bool, stats *retryCounters, send func() error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || !retryable(err, idempotent) {
+			return err
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			stats.add(0, 1)
+			return errors.Wrapf(err, "giving up after %d attempts", attempt)
+		}
+		select {
+		case <-time.After(p.backoff(attempt - 1)):
+		case <-ctx.Done():
+			return err
+		}
+		stats.add(1, 0)
+	}
+}
+
+// RetryStats counts the requests sent again by a retry policy.
+type RetryStats struct {
+	Retries   int64 // Retries is the number of times requests were sent again
+	Exhausted int64 // Exhausted is the number of requests that were still failing after their last attempt
+}
+
+// retryCounters accumulates the RetryStats of a client or a pool
+type retryCounters struct {
+	mu    sync.Mutex
+	stats RetryStats
+}
+
+func (r *retryCounters) add(retries, exhausted int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Retries += retries
+	r.stats.Exhausted += exhausted
+}
+
+func (r *retryCounters) get() RetryStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
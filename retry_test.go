@@ -0,0 +1,134 @@
+package gremtune
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+// neptuneError is the status message Neptune fails a request with
+func neptuneError(code string) string {
+	return fmt.Sprintf(`{"requestId":"x","code":"%s","detailedMessage":"failed"}`, code)
+}
+
+// newFailingServer starts a server failing the first failures requests with the Neptune error code before
+// answering
+func newFailingServer(failures int, code string) *gremtunetest.Server {
+	srv := gremtunetest.NewServer()
+	srv.On(gremtunetest.Any(), gremtunetest.Success(`["ok"]`))
+	srv.On(firstRequests(failures), gremtunetest.Error(statusServerError, neptuneError(code)))
+	return srv
+}
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 2}
+}
+
+func TestClientRetry(t *testing.T) {
+	srv := newFailingServer(2, NeptuneConcurrentModification)
+	defer srv.Close()
+
+	c, err := Dial(NewDialer(srv.URL(), SetRetryPolicy(testRetryPolicy())), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.Execute("g.addV('person')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("Expected the request to be sent 3 times, got %d", n)
+	}
+	if stats := c.RetryStats(); stats.Retries != 2 || stats.Exhausted != 0 {
+		t.Errorf("Expected 2 retries, got %+v", stats)
+	}
+}
+
+func TestClientRetryExhausted(t *testing.T) {
+	srv := newFailingServer(10, NeptuneThrottling)
+	defer srv.Close()
+
+	c, err := Dial(NewDialer(srv.URL(), SetRetryPolicy(testRetryPolicy())), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.Execute("g.V()")
+	if NeptuneCode(err) != NeptuneThrottling {
+		t.Errorf("Expected the throttling error after the last attempt, got %v", err)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("Expected the request to be sent 3 times, got %d", n)
+	}
+	if stats := c.RetryStats(); stats.Retries != 2 || stats.Exhausted != 1 {
+		t.Errorf("Expected 2 retries and 1 exhausted request, got %+v", stats)
+	}
+}
+
+func TestClientNoRetry(t *testing.T) {
+	srv := newFailingServer(1, NeptuneTimeLimitExceeded)
+	defer srv.Close()
+
+	c, err := Dial(NewDialer(srv.URL(), SetRetryPolicy(testRetryPolicy())), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.Execute("g.V()"); err == nil {
+		t.Error("Expected the time limit to fail the request")
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("Expected the request to be sent once, got %d", n)
+	}
+}
+
+func TestPoolRetry(t *testing.T) {
+	srv := newFailingServer(1, NeptuneReadOnlyViolation)
+	defer srv.Close()
+
+	policy := testRetryPolicy()
+	p := &Pool{RetryPolicy: &policy, Dial: func() (*Client, error) {
+		c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+		return &c, err
+	}}
+	defer p.Close()
+
+	if _, err := p.ExecuteWithOptionsContext(context.Background(), "g.addV('person')", RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.RetryStats(); stats.Retries != 1 {
+		t.Errorf("Expected 1 retry, got %+v", stats)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	lost := errors.Wrap(ErrConnectionLost, "query")
+	if DefaultRetryable(lost, false) || !DefaultRetryable(lost, true) {
+		t.Error("Expected a lost connection to be retried for idempotent requests only")
+	}
+	conflict := &ResponseError{Code: statusServerError, Message: `{"code":"ConcurrentModificationException"}`}
+	if !DefaultRetryable(conflict, false) {
+		t.Error("Expected a concurrent modification to be retried")
+	}
+	memory := &ResponseError{Code: statusServerError, Message: `{"code":"MemoryLimitExceededException"}`}
+	if DefaultRetryable(memory, false) || !DefaultRetryable(memory, true) {
+		t.Error("Expected an exceeded memory limit to be retried for idempotent requests only")
+	}
+	queue := &ResponseError{Code: statusServerError, Message: `{"code":"QueryLimitExceededException"}`}
+	if DefaultRetryable(queue, false) || !DefaultRetryable(queue, true) {
+		t.Error("Expected an exceeded query limit to be retried for idempotent requests only")
+	}
+	if DefaultRetryable(errors.Wrap(ErrConnectionClosed, "query"), true) || DefaultRetryable(context.Canceled, true) {
+		t.Error("Expected closed connections and cancelled requests not to be retried")
+	}
+	if NeptuneCode(&ResponseError{Message: "not json"}) != "" {
+		t.Error("Expected no Neptune code in a plain message")
+	}
+}
@@ -0,0 +1,111 @@
+package gremtune
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// Router routes requests between the writer and the readers of a Neptune cluster. Read-only requests go to the
+// readers: the ones declared with RequestOptions.ReadOnly, and the traversals without steps adding, changing or
+// dropping elements. Every other request, including scripts not declared read-only, goes to the writer.
+//
+// After a failover the connections to the writer may reach an instance that became a reader, which refuses writes
+// with a ReadOnlyViolationException. The writer pool then dials new connections, resolving the writer again, and
+// the request is sent once more, or as many times as the RetryPolicy of the writer pool allows when it has one.
+type Router struct {
+	Writer  *Pool        // Writer holds the connections to the writer, such as the cluster endpoint of Neptune
+	Readers *ClusterPool // Readers spreads the read-only requests, which go to the writer without readers up
+}
+
+// Close closes the connections to the writer and the readers.
+func (r *Router) Close() {
+	r.Writer.Close()
+	if r.Readers != nil {
+		r.Readers.Close()
+	}
+}
+
+// ExecuteWithBindings formats a raw Gremlin query, sends it to the writer, and returns the result.
+func (r *Router) ExecuteWithBindings(query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return r.ExecuteWithBindingsContext(context.Background(), query, bindings, rebindings)
+}
+
+// ExecuteWithBindingsContext is like ExecuteWithBindings but gives up waiting for a connection or for the writer
+// once ctx is done.
+func (r *Router) ExecuteWithBindingsContext(ctx context.Context, query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return r.route(ctx, false, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithBindingsContext(ctx, query, bindings, rebindings)
+	})
+}
+
+// Execute formats a raw Gremlin query, sends it to the writer, and returns the result.
+func (r *Router) Execute(query string) (resp []Response, err error) {
+	return r.ExecuteContext(context.Background(), query)
+}
+
+// ExecuteContext is like Execute but gives up waiting for a connection or for the writer once ctx is done.
+func (r *Router) ExecuteContext(ctx context.Context, query string) (resp []Response, err error) {
+	return r.route(ctx, false, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteContext(ctx, query)
+	})
+}
+
+// ExecuteWithTypedBindings sends a raw Gremlin query to the writer with bindings of any type, and returns the
+// result.
+func (r *Router) ExecuteWithTypedBindings(query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return r.ExecuteWithTypedBindingsContext(context.Background(), query, bindings)
+}
+
+// ExecuteWithTypedBindingsContext is like ExecuteWithTypedBindings but gives up waiting for a connection or for
+// the writer once ctx is done.
+func (r *Router) ExecuteWithTypedBindingsContext(ctx context.Context, query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return r.route(ctx, false, false, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithTypedBindingsContext(ctx, query, bindings)
+	})
+}
+
+// ExecuteWithOptions sends a raw Gremlin query with the options to the readers when it is declared read-only,
+// and to the writer otherwise, and returns the result.
+func (r *Router) ExecuteWithOptions(query string, options RequestOptions) (resp []Response, err error) {
+	return r.ExecuteWithOptionsContext(context.Background(), query, options)
+}
+
+// ExecuteWithOptionsContext is like ExecuteWithOptions but gives up waiting for a connection or for the server
+// once ctx is done.
+func (r *Router) ExecuteWithOptionsContext(ctx context.Context, query string, options RequestOptions) (resp []Response, err error) {
+	return r.route(ctx, options.ReadOnly, options.Idempotent, func(c *Client) ([]Response, error) {
+		return c.ExecuteWithOptionsContext(ctx, query, options)
+	})
+}
+
+// Submit sends the traversal as bytecode to the readers when it is read-only, and to the writer otherwise, and
+// returns the result.
+func (r *Router) Submit(t *traversal.Traversal) (resp []Response, err error) {
+	return r.SubmitContext(context.Background(), t)
+}
+
+// SubmitContext is like Submit but gives up waiting for a connection or for the server once ctx is done.
+func (r *Router) SubmitContext(ctx context.Context, t *traversal.Traversal) (resp []Response, err error) {
+	return r.route(ctx, t.ReadOnly(), false, func(c *Client) ([]Response, error) {
+		return c.SubmitContext(ctx, t)
+	})
+}
+
+// route sends read-only requests to the readers while one is up and the others to the writer, once more on new
+// connections when the writer turns out to have failed over.
+func (r *Router) route(ctx context.Context, readOnly, idempotent bool, send func(c *Client) ([]Response, error)) (resp []Response, err error) {
+	if readOnly && r.Readers != nil {
+		resp, err = r.Readers.execute(ctx, idempotent, send)
+		if !errors.Is(err, ErrNoHostAvailable) {
+			return
+		}
+	}
+	resp, err = r.Writer.execute(ctx, idempotent, send)
+	// The writer pool was reset, dial the new writer unless the retry policy of the pool already did
+	if r.Writer.RetryPolicy == nil && NeptuneCode(err) == NeptuneReadOnlyViolation {
+		resp, err = r.Writer.execute(ctx, idempotent, send)
+	}
+	return
+}
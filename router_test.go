@@ -0,0 +1,92 @@
+package gremtune
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/schwartzmx/gremtune/gremtunetest"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+func TestRouterRoutesReads(t *testing.T) {
+	writer := newNamedServer("writer")
+	defer writer.Close()
+	reader := newNamedServer("reader")
+	defer reader.Close()
+
+	r := &Router{
+		Writer:  &Pool{Dial: func() (*Client, error) { return dialCluster(writer.URL()) }},
+		Readers: &ClusterPool{Hosts: []string{reader.URL()}, Dial: dialCluster},
+	}
+	defer r.Close()
+
+	cases := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{"read traversal", answeredBy(r.Submit(traversal.G.V().Values("name"))), "reader"},
+		{"write traversal", answeredBy(r.Submit(traversal.G.AddV("person"))), "writer"},
+		{"read-only script", answeredBy(r.ExecuteWithOptions("g.V()", RequestOptions{ReadOnly: true})), "reader"},
+		{"script", answeredBy(r.Execute("g.V()")), "writer"},
+	}
+	for _, c := range cases {
+		if c.host != c.expected {
+			t.Errorf("Expected the %s to go to the %s, got %s", c.name, c.expected, c.host)
+		}
+	}
+
+	r.Readers.markDown(r.Readers.hosts[0], ErrConnectionLost)
+	if host := answeredBy(r.Submit(traversal.G.V())); host != "writer" {
+		t.Errorf("Expected reads to go to the writer without readers up, got %s", host)
+	}
+}
+
+func TestRouterResolvesWriterAfterFailover(t *testing.T) {
+	var failedOver int32
+	former := newNamedServer("former")
+	defer former.Close()
+	former.On(func(gremtunetest.Request) bool { return atomic.LoadInt32(&failedOver) == 1 }, // The former writer is now a reader
+		gremtunetest.Error(statusServerError, `{"code":"ReadOnlyViolationException","detailedMessage":"The request is rejected because it violates some read-only constraint"}`))
+	promoted := newNamedServer("promoted")
+	defer promoted.Close()
+
+	// The cluster endpoint resolves to the promoted instance once failed over
+	dial := func() (*Client, error) {
+		if atomic.LoadInt32(&failedOver) == 1 {
+			return dialCluster(promoted.URL())
+		}
+		return dialCluster(former.URL())
+	}
+	r := &Router{Writer: &Pool{Dial: dial}}
+	defer r.Close()
+
+	if host := answeredBy(r.Submit(traversal.G.AddV("person"))); host != "former" {
+		t.Fatalf("Expected the write to go to the writer, got %s", host)
+	}
+	atomic.StoreInt32(&failedOver, 1)
+	if host := answeredBy(r.Submit(traversal.G.AddV("person"))); host != "promoted" {
+		t.Errorf("Expected the write to be sent again to the promoted writer, got %s", host)
+	}
+	if host := answeredBy(r.Submit(traversal.G.AddV("person"))); host != "promoted" {
+		t.Errorf("Expected later writes to go to the promoted writer, got %s", host)
+	}
+}
+
+// TestRouterWriterRetryPolicy tests that the router leaves resending writes after a failover to the retry policy
+// of the writer pool when it has one
+func TestRouterWriterRetryPolicy(t *testing.T) {
+	srv := newFailingServer(100, NeptuneReadOnlyViolation)
+	defer srv.Close()
+
+	policy := testRetryPolicy()
+	r := &Router{Writer: &Pool{RetryPolicy: &policy, Dial: func() (*Client, error) { return dialCluster(srv.URL()) }}}
+	defer r.Close()
+
+	if _, err := r.Submit(traversal.G.AddV("person")); NeptuneCode(err) != NeptuneReadOnlyViolation {
+		t.Fatalf("Expected the read-only violation, got %v", err)
+	}
+	if n := len(srv.Requests()); n != policy.MaxAttempts {
+		t.Errorf("Expected the write to be sent %d times, got %d", policy.MaxAttempts, n)
+	}
+}
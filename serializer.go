@@ -0,0 +1,133 @@
+package gremtune
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/graphbinary"
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/traversal"
+)
+
+// Serializer is the format requests are written in and responses read in. Gremlin Server picks the format from the
+// mime type the client announces at the head of every request, so the server must have a serializer configured for
+// it. Use one of GraphSONv1, GraphSONv2, GraphSONv3, the default, and GraphBinary.
+type Serializer interface {
+	// MimeType returns the mime type announced to Gremlin Server
+	MimeType() string
+
+	encodeRequest(req request) ([]byte, error)
+	decodeResponse(msg []byte) (Response, error)
+	decodeResult(r Result) (interface{}, error)
+}
+
+// The GraphSON serializers. Results of every version are decoded to the same Go values, see graphson.UnmarshalVersion.
+var (
+	GraphSONv1 Serializer = graphSONSerializer{version: graphson.V1}
+	GraphSONv2 Serializer = graphSONSerializer{version: graphson.V2}
+	GraphSONv3 Serializer = graphSONSerializer{version: graphson.V3}
+)
+
+// GraphBinary is the GraphBinary 1.0 serializer, more compact and faster to read than GraphSON. Results are decoded
+// to the same Go values as GraphSON, see graphbinary.Unmarshal, and Result.Data of its responses is left empty.
+var GraphBinary Serializer = graphBinarySerializer{}
+
+// graphSONSerializer writes and reads one version of GraphSON
+type graphSONSerializer struct {
+	version graphson.Version
+}
+
+func (s graphSONSerializer) MimeType() string {
+	return fmt.Sprintf("application/vnd.gremlin-v%d.0+json", s.version)
+}
+
+func (s graphSONSerializer) encodeRequest(req request) ([]byte, error) {
+	args := make(map[string]interface{}, len(req.Args))
+	for key, value := range req.Args {
+		switch v := value.(type) {
+		case *traversal.Traversal: // Bytecode is typed, unlike the other arguments
+			bytecode, err := graphson.MarshalVersion(v, s.version)
+			if err != nil {
+				return nil, err
+			}
+			value = json.RawMessage(bytecode)
+		case map[string]interface{}: // Typed bindings, each value written with its type
+			if key != "bindings" {
+				break // Other arguments, such as the ones of RequestOptions.Args, are written as they are
+			}
+			bindings := make(map[string]json.RawMessage, len(v))
+			for name, binding := range v {
+				b, err := graphson.MarshalVersion(binding, s.version)
+				if err != nil {
+					return nil, errors.Wrapf(err, "binding %s", name)
+				}
+				bindings[name] = b
+			}
+			value = bindings
+		}
+		args[key] = value
+	}
+	req.Args = args
+	if s.version != graphson.V2 {
+		return json.Marshal(req)
+	}
+	// GraphSON 2.0 request messages carry the request id as a g:UUID
+	return json.Marshal(struct {
+		RequestID typedValue             `json:"requestId"`
+		Op        string                 `json:"op"`
+		Processor string                 `json:"processor"`
+		Args      map[string]interface{} `json:"args"`
+	}{typedValue{Type: "g:UUID", Value: req.RequestID}, req.Op, req.Processor, req.Args})
+}
+
+func (s graphSONSerializer) decodeResponse(msg []byte) (resp Response, err error) {
+	resp, err = marshalResponse(msg)
+	resp.serializer = s
+	return
+}
+
+func (s graphSONSerializer) decodeResult(r Result) (interface{}, error) {
+	return graphson.UnmarshalVersion(r.Data, s.version)
+}
+
+// graphBinarySerializer writes and reads GraphBinary 1.0
+type graphBinarySerializer struct{}
+
+func (graphBinarySerializer) MimeType() string {
+	return graphbinary.MimeType
+}
+
+func (graphBinarySerializer) encodeRequest(req request) ([]byte, error) {
+	id, err := uuid.FromString(req.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	return graphbinary.EncodeRequest(graphbinary.RequestMessage{RequestID: id, Op: req.Op, Processor: req.Processor, Args: req.Args})
+}
+
+func (s graphBinarySerializer) decodeResponse(msg []byte) (resp Response, err error) {
+	m, err := graphbinary.DecodeResponse(msg)
+	if err != nil {
+		return
+	}
+	if m.RequestID != uuid.Nil {
+		resp.RequestID = m.RequestID.String()
+	}
+	resp.Status = Status{Message: m.StatusMessage, Code: m.StatusCode, Attributes: m.StatusAttributes}
+	resp.Result = Result{Meta: m.ResultMeta, decoded: m.ResultData}
+	resp.serializer = s
+	err = resp.detectError()
+	return
+}
+
+func (graphBinarySerializer) decodeResult(r Result) (interface{}, error) {
+	return r.decoded, nil
+}
+
+// typedValue is a value with its GraphSON type
+type typedValue struct {
+	Type  string      `json:"@type"`
+	Value interface{} `json:"@value"`
+}
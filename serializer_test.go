@@ -0,0 +1,239 @@
+package gremtune
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/schwartzmx/gremtune/graphbinary"
+	"github.com/schwartzmx/gremtune/graphson"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+// TestSerializerPackaging tests that every serializer announces its mime type with its length
+func TestSerializerPackaging(t *testing.T) {
+	req, id, err := prepareRequest("g.V()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		serializer Serializer
+		mimeType   string
+		requestID  interface{}
+	}{
+		{GraphSONv1, "application/vnd.gremlin-v1.0+json", id},
+		{GraphSONv2, "application/vnd.gremlin-v2.0+json", map[string]interface{}{"@type": "g:UUID", "@value": id}},
+		{GraphSONv3, "application/vnd.gremlin-v3.0+json", id},
+	}
+	for _, tc := range cases {
+		msg, err := packageRequest(req, tc.serializer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(msg[0]) != len(tc.mimeType) || string(msg[1:msg[0]+1]) != tc.mimeType {
+			t.Errorf("Expected mime type header %q, got %q", tc.mimeType, msg[:msg[0]+1])
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(msg[msg[0]+1:], &body); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(body["requestId"], tc.requestID) {
+			t.Errorf("Expected %s request id %v, got %v", tc.mimeType, tc.requestID, body["requestId"])
+		}
+	}
+}
+
+// TestTypedBindings tests that typed bindings are written with their types by every serializer
+func TestTypedBindings(t *testing.T) {
+	bindings := map[string]interface{}{"age": int64(29), "weight": 0.5, "active": true, "names": []string{"a", "b"}}
+	req, _, err := prepareRequestWithTypedBindings("g.V().has('age', age)", bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		serializer Serializer
+		expected   string
+	}{
+		{GraphSONv1, `{"active":true,"age":29,"names":["a","b"],"weight":0.5}`},
+		{GraphSONv2, `{"active":true,"age":{"@type":"g:Int64","@value":29},"names":["a","b"],"weight":{"@type":"g:Double","@value":0.5}}`},
+		{GraphSONv3, `{"active":true,"age":{"@type":"g:Int64","@value":29},"names":{"@type":"g:List","@value":["a","b"]},"weight":{"@type":"g:Double","@value":0.5}}`},
+	}
+	for _, tc := range cases {
+		msg, err := packageRequest(req, tc.serializer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Args struct {
+				Bindings json.RawMessage `json:"bindings"`
+			} `json:"args"`
+		}
+		if err := json.Unmarshal(msg[msg[0]+1:], &body); err != nil {
+			t.Fatal(err)
+		}
+		if string(body.Args.Bindings) != tc.expected {
+			t.Errorf("Expected %s bindings %s, got %s", tc.serializer.MimeType(), tc.expected, body.Args.Bindings)
+		}
+	}
+
+	msg, err := packageRequest(req, GraphBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := graphbinary.DecodeRequest(msg[msg[0]+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := graphson.Map{
+		{Key: "active", Value: true},
+		{Key: "age", Value: int64(29)},
+		{Key: "names", Value: graphson.List{"a", "b"}},
+		{Key: "weight", Value: 0.5},
+	}
+	if !reflect.DeepEqual(decoded.Args["bindings"], expected) {
+		t.Errorf("Expected GraphBinary bindings %#v, got %#v", expected, decoded.Args["bindings"])
+	}
+}
+
+// TestMapArgs tests that only the bindings are typed, other arguments holding a map are written as they are
+func TestMapArgs(t *testing.T) {
+	r := &evalRequest{query: "g.V()", options: &RequestOptions{Args: map[string]interface{}{"hints": map[string]interface{}{"limit": int64(10)}}}}
+	if err := r.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := packageRequest(r.getRequest(), GraphSONv3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Args struct {
+			Hints json.RawMessage `json:"hints"`
+		} `json:"args"`
+	}
+	if err := json.Unmarshal(msg[msg[0]+1:], &body); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"limit":10}`; string(body.Args.Hints) != expected {
+		t.Errorf("Expected the argument %s, got %s", expected, body.Args.Hints)
+	}
+}
+
+// TestSetSerializer tests that the client writes requests and decodes responses with the serializer of the dialer
+func TestSetSerializer(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Success(`[{"name":"marko"}]`))
+
+	c, err := Dial(NewDialer(srv.URL(), SetSerializer(GraphSONv2)), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	resp, err := c.Execute("g.V().valueMap()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mimeType := srv.Requests()[0].MimeType; mimeType != GraphSONv2.MimeType() {
+		t.Errorf("Expected request in %s, got %s", GraphSONv2.MimeType(), mimeType)
+	}
+	v, err := resp[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := graphson.List{graphson.Map{{Key: "name", Value: "marko"}}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+}
+
+// TestGraphBinarySerializer tests that the client talks GraphBinary to a server when asked to
+func TestGraphBinarySerializer(t *testing.T) {
+	received := make(chan graphbinary.RequestMessage, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if mimeType := string(msg[1 : msg[0]+1]); mimeType != graphbinary.MimeType {
+			t.Errorf("Expected request in %s, got %s", graphbinary.MimeType, mimeType)
+		}
+		req, err := graphbinary.DecodeRequest(msg[msg[0]+1:])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- req
+		resp, err := graphbinary.EncodeResponse(graphbinary.ResponseMessage{
+			RequestID:  req.RequestID,
+			StatusCode: statusSuccess,
+			ResultData: graphson.List{graphson.Vertex{ID: int64(1), Label: "person"}},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.WriteMessage(websocket.BinaryMessage, resp)
+		conn.ReadMessage() // Wait for the client to close
+	}))
+	defer srv.Close()
+
+	c, err := Dial(NewDialer("ws"+strings.TrimPrefix(srv.URL, "http"), SetSerializer(GraphBinary)), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	resp, err := c.ExecuteWithBindings("g.V(x)", map[string]string{"x": "1"}, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-received
+	if req.Op != "eval" || req.Args["gremlin"] != "g.V(x)" {
+		t.Errorf("Unexpected request %#v", req)
+	}
+	if bindings := req.Args["bindings"]; !reflect.DeepEqual(bindings, graphson.Map{{Key: "x", Value: "1"}}) {
+		t.Errorf("Unexpected bindings %#v", bindings)
+	}
+	if resp[0].RequestID != req.RequestID.String() {
+		t.Errorf("Expected response to %s, got %s", req.RequestID, resp[0].RequestID)
+	}
+	v, err := resp[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (graphson.List{graphson.Vertex{ID: int64(1), Label: "person"}}); !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, v)
+	}
+}
+
+// TestGraphBinaryErrorResponse tests that an error status read in GraphBinary fails the request
+func TestGraphBinaryErrorResponse(t *testing.T) {
+	msg, err := graphbinary.EncodeResponse(graphbinary.ResponseMessage{
+		RequestID:     uuid.Must(uuid.NewV4()),
+		StatusCode:    statusScriptEvaluationError,
+		StatusMessage: "No such property: x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := GraphBinary.decodeResponse(msg)
+	if err == nil {
+		t.Error("Expected an error for the script evaluation error")
+	}
+	if resp.Status.Message != "No such property: x" {
+		t.Errorf("Unexpected status %#v", resp.Status)
+	}
+}
@@ -0,0 +1,229 @@
+package gremtune
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrSessionClosed is returned by the requests of a session that has been closed.
+var ErrSessionClosed = errors.New("session closed")
+
+// Session evaluates scripts in a session of Gremlin Server, which keeps the variables of a script for the next
+// and runs them in one transaction until it is committed or rolled back. A session lives on a single connection
+// and Gremlin Server evaluates its scripts one after the other. Close must be called once done with the session.
+// Cancelling one of its requests through the context closes the session, as Gremlin Server can only stop a
+// script by closing its session.
+type Session struct {
+	client            *Client
+	id                string
+	manageTransaction bool
+	release           func() // release returns the connection of a pooled session to its pool
+	mu                sync.Mutex
+	closed            bool
+}
+
+// SessionConfig is the type for configuring a session
+type SessionConfig func(*Session)
+
+// SetManageTransaction makes Gremlin Server commit the transaction after every successful script of the session
+// and roll it back after every failed one, instead of waiting for Commit or Rollback.
+func SetManageTransaction(manage bool) SessionConfig {
+	return func(s *Session) {
+		s.manageTransaction = manage
+	}
+}
+
+// SetSessionID sets the id of the session instead of a random UUID, to share a session between clients.
+func SetSessionID(id string) SessionConfig {
+	return func(s *Session) {
+		s.id = id
+	}
+}
+
+// NewSession opens a session on the connection of the client. Gremlin Server creates the session with its first
+// request.
+func (c *Client) NewSession(ctx context.Context, configs ...SessionConfig) (*Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.conn.IsDisposed() {
+		return nil, errors.New("you cannot write on disposed connection")
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{client: c, id: id.String()}
+	for _, conf := range configs {
+		conf(s)
+	}
+	return s, nil
+}
+
+// NewSession grabs a connection from the pool and opens a session on it. The connection is kept by the session
+// until it is closed.
+func (p *Pool) NewSession(ctx context.Context, configs ...SessionConfig) (*Session, error) {
+	pc, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := pc.Client.NewSession(ctx, configs...)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	s.release = pc.Close
+	return s, nil
+}
+
+// ID returns the id of the session.
+func (s *Session) ID() string {
+	return s.id
+}
+
+func (s *Session) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// execute evaluates the request in the session
+func (s *Session) execute(ctx context.Context, r *evalRequest) (resp []Response, err error) {
+	if s.isClosed() {
+		return nil, ErrSessionClosed
+	}
+	if s.client.conn.IsDisposed() {
+		return resp, errors.New("you cannot write on disposed connection")
+	}
+	r.session = s.id
+	r.manageTransaction = s.manageTransaction
+	resp, err = s.client.executeEval(ctx, r)
+	if err != nil && errors.Cause(err) == ctx.Err() {
+		s.cancel()
+	}
+	return
+}
+
+// cancel closes the session once one of its requests was cancelled. Gremlin Server cannot stop a single script,
+// closing the session without waiting for it does, rolling back the transaction.
+func (s *Session) cancel() {
+	if !s.markClosed() {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), closeRequestTimeout)
+		defer cancel()
+		s.close(ctx, true)
+	}()
+}
+
+// Execute sends a raw Gremlin query to be evaluated in the session and returns the result.
+func (s *Session) Execute(query string) (resp []Response, err error) {
+	return s.ExecuteContext(context.Background(), query)
+}
+
+// ExecuteContext is like Execute but gives up waiting for Gremlin Server once ctx is done.
+func (s *Session) ExecuteContext(ctx context.Context, query string) (resp []Response, err error) {
+	return s.execute(ctx, &evalRequest{query: query})
+}
+
+// ExecuteWithBindings sends a raw Gremlin query with bindings to be evaluated in the session and returns the result.
+func (s *Session) ExecuteWithBindings(query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return s.ExecuteWithBindingsContext(context.Background(), query, bindings, rebindings)
+}
+
+// ExecuteWithBindingsContext is like ExecuteWithBindings but gives up waiting for Gremlin Server once ctx is done.
+func (s *Session) ExecuteWithBindingsContext(ctx context.Context, query string, bindings, rebindings map[string]string) (resp []Response, err error) {
+	return s.execute(ctx, &evalRequest{query: query, bindings: &bindings, rebindings: &rebindings})
+}
+
+// ExecuteWithTypedBindings sends a raw Gremlin query with bindings of any type to be evaluated in the session and
+// returns the result.
+func (s *Session) ExecuteWithTypedBindings(query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return s.ExecuteWithTypedBindingsContext(context.Background(), query, bindings)
+}
+
+// ExecuteWithTypedBindingsContext is like ExecuteWithTypedBindings but gives up waiting for Gremlin Server once
+// ctx is done.
+func (s *Session) ExecuteWithTypedBindingsContext(ctx context.Context, query string, bindings map[string]interface{}) (resp []Response, err error) {
+	return s.execute(ctx, &evalRequest{query: query, typedBindings: bindings})
+}
+
+// ExecuteWithOptions sends a raw Gremlin query with the options to be evaluated in the session and returns the
+// result.
+func (s *Session) ExecuteWithOptions(query string, options RequestOptions) (resp []Response, err error) {
+	return s.ExecuteWithOptionsContext(context.Background(), query, options)
+}
+
+// ExecuteWithOptionsContext is like ExecuteWithOptions but gives up waiting for Gremlin Server once ctx is done.
+func (s *Session) ExecuteWithOptionsContext(ctx context.Context, query string, options RequestOptions) (resp []Response, err error) {
+	return s.execute(ctx, &evalRequest{query: query, options: &options})
+}
+
+// Commit commits the transaction of the session.
+func (s *Session) Commit() error {
+	return s.CommitContext(context.Background())
+}
+
+// CommitContext is like Commit but gives up waiting for Gremlin Server once ctx is done.
+func (s *Session) CommitContext(ctx context.Context) error {
+	_, err := s.execute(ctx, &evalRequest{query: "g.tx().commit()"})
+	return err
+}
+
+// Rollback rolls back the transaction of the session.
+func (s *Session) Rollback() error {
+	return s.RollbackContext(context.Background())
+}
+
+// RollbackContext is like Rollback but gives up waiting for Gremlin Server once ctx is done.
+func (s *Session) RollbackContext(ctx context.Context) error {
+	_, err := s.execute(ctx, &evalRequest{query: "g.tx().rollback()"})
+	return err
+}
+
+// Close closes the session on Gremlin Server, which rolls back whatever was left uncommitted, and returns the
+// connection of a pooled session to its pool. Closing a closed session does nothing.
+func (s *Session) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeRequestTimeout)
+	defer cancel()
+	return s.CloseContext(ctx)
+}
+
+// CloseContext is like Close but gives up waiting for Gremlin Server once ctx is done. The session is closed on
+// the client either way.
+func (s *Session) CloseContext(ctx context.Context) (err error) {
+	if !s.markClosed() {
+		return nil
+	}
+	return s.close(ctx, false)
+}
+
+// markClosed closes the session on the client and reports whether it was open
+func (s *Session) markClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closed = true
+	return true
+}
+
+// close closes the session on Gremlin Server and returns the connection of a pooled session to its pool
+func (s *Session) close(ctx context.Context, force bool) (err error) {
+	if s.release != nil {
+		defer s.release()
+	}
+	if s.client.conn.IsDisposed() {
+		return nil // The session died with the connection
+	}
+	id, err := s.client.submitRequest(ctx, &sessionCloseRequest{session: s.id, force: force})
+	if err == nil {
+		_, err = s.client.retrieveResponse(ctx, id)
+	}
+	return errors.Wrap(err, "closing session")
+}
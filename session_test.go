@@ -0,0 +1,140 @@
+package gremtune
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+func TestSessionRequests(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Op("eval"), gremtunetest.Success(`["ok"]`))
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s, err := c.NewSession(context.Background(), SetManageTransaction(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.ExecuteWithBindings("g.addV(x)", map[string]string{"x": "person"}, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	for i, query := range []string{"g.addV(x)", "g.tx().commit()"} {
+		req := requests[i]
+		if req.Op != "eval" || req.Processor != "session" {
+			t.Errorf("Expected request %d to be a session eval, got %s %s", i, req.Op, req.Processor)
+		}
+		if req.Script() != query {
+			t.Errorf("Expected request %d to evaluate %s, got %v", i, query, req.Script())
+		}
+		if requestArg(req, "session") != s.ID() {
+			t.Errorf("Expected request %d in session %s, got %v", i, s.ID(), requestArg(req, "session"))
+		}
+		if requestArg(req, "manageTransaction") != true {
+			t.Errorf("Expected request %d to manage the transaction, got %v", i, requestArg(req, "manageTransaction"))
+		}
+	}
+	req := requests[2]
+	if req.Op != "close" || req.Processor != "session" || requestArg(req, "session") != s.ID() {
+		t.Errorf("Expected the session to be closed, got %s %s %s", req.Op, req.Processor, req.Args)
+	}
+
+	if _, err = s.Execute("g.V()"); err != ErrSessionClosed {
+		t.Errorf("Expected ErrSessionClosed after closing, got %v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Errorf("Expected closing twice to do nothing, got %v", err)
+	}
+}
+
+// TestSessionCancel tests that cancelling a request of a session closes the session on Gremlin Server
+func TestSessionCancel(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Op("eval"), gremtunetest.Dropped()) // The script never completes
+
+	c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s, err := c.NewSession(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = s.ExecuteContext(ctx, "Thread.sleep(60000)"); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	requests := waitForRequests(t, srv, 2)
+	if requests[0].Op != "eval" {
+		t.Fatalf("Expected the script to be evaluated first, got %s", requests[0].Op)
+	}
+	if req := requests[1]; req.Op != "close" || req.Processor != "session" || requestArg(req, "session") != s.ID() || requestArg(req, "force") != true {
+		t.Errorf("Expected the session to be closed by force, got %s %s %s", req.Op, req.Processor, req.Args)
+	}
+	if _, err = s.Execute("g.V()"); err != ErrSessionClosed {
+		t.Errorf("Expected ErrSessionClosed after cancelling, got %v", err)
+	}
+}
+
+// TestPoolSessionPinsConnection tests that a pooled session keeps its connection until it is closed
+func TestPoolSessionPinsConnection(t *testing.T) {
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.On(gremtunetest.Any(), gremtunetest.Success(`["ok"]`))
+
+	dials := 0
+	p := &Pool{MaxActive: 1, Dial: func() (*Client, error) {
+		dials++
+		c, err := Dial(NewDialer(srv.URL()), make(chan error, 1))
+		return &c, err
+	}}
+	defer p.Close()
+
+	s, err := p.NewSession(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Execute("x = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = p.ExecuteContext(ctx, "g.V()"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the session to hold the only connection, got %v", err)
+	}
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Execute("g.V()"); err != nil {
+		t.Fatal(err)
+	}
+	if dials != 1 {
+		t.Errorf("Expected the connection of the session to be reused, got %d dials", dials)
+	}
+}
@@ -0,0 +1,172 @@
+package gremtune
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	neptuneService   = "neptune-db"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // sha256 of no payload
+)
+
+// Credentials are the AWS credentials used to sign the connection to Neptune.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // SessionToken is only set for temporary credentials, e.g. from STS
+}
+
+// CredentialsProvider supplies the AWS credentials used to sign the connection to Neptune. It is asked for
+// credentials on every dial, so providers of rotating credentials keep working across reconnects.
+type CredentialsProvider interface {
+	Retrieve() (Credentials, error)
+}
+
+// CredentialsProviderFunc adapts a function to a CredentialsProvider.
+type CredentialsProviderFunc func() (Credentials, error)
+
+// Retrieve returns the credentials returned by the function.
+func (f CredentialsProviderFunc) Retrieve() (Credentials, error) {
+	return f()
+}
+
+// StaticCredentials is a CredentialsProvider which always returns the same credentials.
+type StaticCredentials Credentials
+
+// Retrieve returns the static credentials.
+func (c StaticCredentials) Retrieve() (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials returns a CredentialsProvider reading the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+func EnvCredentials() CredentialsProvider {
+	return CredentialsProviderFunc(func() (Credentials, error) {
+		creds := Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return creds, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+		}
+		return creds, nil
+	})
+}
+
+// sigV4Signer signs requests with AWS Signature Version 4.
+type sigV4Signer struct {
+	region      string
+	service     string
+	credentials CredentialsProvider
+	now         func() time.Time
+}
+
+// signHandshake adds the headers authenticating the websocket upgrade request to the given url. A Host header
+// already set, e.g. when tunnelling to Neptune, is signed in place of the url's host.
+func (s *sigV4Signer) signHandshake(rawurl string, header http.Header) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if host := header.Get("Host"); host != "" {
+		u.Host = host
+	}
+	return s.sign("GET", u, header, emptyPayloadHash)
+}
+
+// sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers for a request to u carrying a
+// payload with the given hex encoded sha256 hash.
+func (s *sigV4Signer) sign(method string, u *url.URL, header http.Header, payloadHash string) error {
+	creds, err := s.credentials.Retrieve()
+	if err != nil {
+		return errors.Wrap(err, "retrieving AWS credentials")
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	header.Set("X-Amz-Date", amzDate)
+	signed := map[string]string{
+		"host":       u.Host,
+		"x-amz-date": amzDate,
+	}
+	if creds.SessionToken != "" {
+		header.Set("X-Amz-Security-Token", creds.SessionToken)
+		signed["x-amz-security-token"] = creds.SessionToken
+	}
+
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(signed[name]))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(u),
+		canonicalQuery(u),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(sigV4DateFormat), s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.Format(sigV4DateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func canonicalURI(u *url.URL) string {
+	if p := u.EscapedPath(); p != "" {
+		return p
+	}
+	return "/"
+}
+
+func canonicalQuery(u *url.URL) string {
+	// Encode sorts by key, AWS wants spaces as %20 rather than +
+	return strings.Replace(u.Query().Encode(), "+", "%20", -1)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,128 @@
+package gremtune
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schwartzmx/gremtune/gremtunetest"
+)
+
+var testCredentials = StaticCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+var testSigningTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+// TestSigV4Vanilla checks the signer against the get-vanilla case of the AWS Signature Version 4 test suite
+func TestSigV4Vanilla(t *testing.T) {
+	s := &sigV4Signer{
+		region:      "us-east-1",
+		service:     "service",
+		credentials: testCredentials,
+		now:         func() time.Time { return testSigningTime },
+	}
+	u, _ := url.Parse("https://example.amazonaws.com/")
+	header := http.Header{}
+	if err := s.sign("GET", u, header, emptyPayloadHash); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := header.Get("Authorization"); got != expected {
+		t.Errorf("Expected authorization header\n%s\ngot\n%s", expected, got)
+	}
+	if got := header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("Unexpected X-Amz-Date %s", got)
+	}
+}
+
+func TestSigV4SessionToken(t *testing.T) {
+	creds := testCredentials
+	creds.SessionToken = "token"
+	s := &sigV4Signer{region: "us-east-1", service: neptuneService, credentials: creds, now: func() time.Time { return testSigningTime }}
+
+	header := http.Header{}
+	if err := s.signHandshake("wss://neptune.example.com:8182/gremlin", header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("Expected security token header")
+	}
+	if !strings.Contains(header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Expected security token to be signed, got %s", header.Get("Authorization"))
+	}
+}
+
+// TestSigV4Handshake tests that the handshake is signed, and signed again with fresh credentials on reconnect
+func TestSigV4Handshake(t *testing.T) {
+	verifier := &sigV4Signer{region: "us-west-2", service: neptuneService, credentials: testCredentials, now: func() time.Time { return testSigningTime }}
+
+	var handshakes int32
+	srv := gremtunetest.NewServer()
+	defer srv.Close()
+	srv.CheckHandshake(func(r *http.Request) error {
+		expected := http.Header{}
+		verifier.sign("GET", &url.URL{Host: r.Host, Path: r.URL.Path}, expected, emptyPayloadHash)
+		if r.Header.Get("Authorization") != expected.Get("Authorization") {
+			return errors.New("bad signature")
+		}
+		return nil
+	})
+	srv.On(gremtunetest.Any(), gremtunetest.Success(`[]`))
+	srv.On(firstRequests(1), gremtunetest.Disconnected()) // Drop the first connection to force a reconnect
+
+	provider := CredentialsProviderFunc(func() (Credentials, error) {
+		atomic.AddInt32(&handshakes, 1)
+		return Credentials(testCredentials), nil
+	})
+	dialer := NewDialer(srv.URL()+"/gremlin",
+		SetSigV4Auth("us-west-2", provider),
+		SetReconnectPolicy(ReconnectPolicy{InitialInterval: 10 * time.Millisecond, MaxAttempts: 5}))
+	dialer.signer.now = func() time.Time { return testSigningTime }
+
+	c, err := Dial(dialer, make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for { // Retry until the reconnected connection serves the request
+		if _, err = c.ExecuteContext(ctx, "g.V()"); err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&handshakes); n < 2 {
+		t.Errorf("Expected handshake to be signed again on reconnect, got %d signatures", n)
+	}
+}
+
+func TestSigV4RejectedWithWrongCredentials(t *testing.T) {
+	verifier := &sigV4Signer{region: "us-west-2", service: neptuneService, credentials: testCredentials, now: time.Now}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := http.Header{}
+		verifier.sign("GET", &url.URL{Host: r.Host, Path: r.URL.Path}, expected, emptyPayloadHash)
+		if r.Header.Get("Authorization") != expected.Get("Authorization") {
+			http.Error(w, "bad signature", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	wrong := StaticCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wrong"}
+	dialer := NewDialer("ws"+strings.TrimPrefix(srv.URL, "http")+"/gremlin", SetSigV4Auth("us-west-2", wrong))
+	if _, err := Dial(dialer, make(chan error, 1)); err == nil {
+		t.Error("Expected handshake with wrong credentials to be rejected")
+	}
+}
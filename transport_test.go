@@ -0,0 +1,101 @@
+package gremtune
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// memTransport is an in-memory transport challenging every request for credentials before answering it
+type memTransport struct {
+	frames   chan []byte
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	requests []request
+}
+
+func newMemTransport() *memTransport {
+	return &memTransport{frames: make(chan []byte, 10), done: make(chan struct{})}
+}
+
+func (m *memTransport) Connect() error        { return nil }
+func (m *memTransport) IsConnected() bool     { return true }
+func (m *memTransport) Ping(errs chan error)  { <-m.done }
+func (m *memTransport) Done() <-chan struct{} { return m.done }
+
+func (m *memTransport) Auth() (username, password string) {
+	return "user", "secret"
+}
+
+func (m *memTransport) IsDisposed() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *memTransport) Write(msg []byte) error {
+	var req request
+	if err := json.Unmarshal(msg[msg[0]+1:], &req); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	m.mu.Unlock()
+	code, data := statusSuccess, `["ok"]`
+	if req.Op == "eval" {
+		code, data = statusAuthenticate, `null`
+	}
+	m.frames <- []byte(fmt.Sprintf(`{"requestId":%q,"status":{"code":%d,"attributes":{},"message":""},"result":{"data":%s,"meta":{}}}`, req.RequestID, code, data))
+	return nil
+}
+
+func (m *memTransport) Read() (int, []byte, error) {
+	select {
+	case msg := <-m.frames:
+		return 1, msg, nil
+	case <-m.done:
+		return 0, nil, ErrConnectionClosed
+	}
+}
+
+func (m *memTransport) Close() error {
+	m.once.Do(func() { close(m.done) })
+	return nil
+}
+
+func TestCustomTransport(t *testing.T) {
+	transport := newMemTransport()
+	c, err := Dial(transport, make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Execute("g.V()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 || string(resp[0].Result.Data) != `["ok"]` {
+		t.Errorf("Expected the response of the transport, got %v", resp)
+	}
+	transport.mu.Lock()
+	ops := []string{}
+	for _, req := range transport.requests {
+		ops = append(ops, req.Op)
+	}
+	transport.mu.Unlock()
+	if fmt.Sprint(ops) != "[eval authentication]" {
+		t.Errorf("Expected the request to be authenticated with the credentials of the transport, got %v", ops)
+	}
+
+	c.Close()
+	if !transport.IsDisposed() {
+		t.Errorf("Expected closing the client to close the transport")
+	}
+	if _, err = c.Execute("g.V()"); err == nil {
+		t.Errorf("Expected requests to fail once the transport is closed")
+	}
+}
@@ -0,0 +1,170 @@
+package traversal
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Script renders the traversal to a Gremlin-Groovy script and its bindings. Strings are bound to the names _0, _1
+// and so on, the same string to the same name, so that they never need escaping and the script stays the same
+// across values. Numbers and booleans are inlined as typed literals, as bindings only hold strings. Values with a
+// String method, such as UUIDs, are strings too. Times are rendered as a java.util.Date of their epoch
+// milliseconds, new Date(1577934245000L), which Groovy script engines evaluate; datetime() is left out as only
+// Gremlin Server 3.7 and later have it.
+func (t *Traversal) Script() (script string, bindings map[string]string, err error) {
+	r := &renderer{bindings: map[string]string{}, names: map[string]string{}}
+	script, err = r.traversal(t)
+	return script, r.bindings, err
+}
+
+// Inline renders the traversal to a Gremlin-Groovy script with strings inlined as escaped literals, for servers
+// that do not support bindings such as Neptune. Other values are rendered as by Script, times included: servers
+// not evaluating Groovy need them as numbers or strings instead.
+func (t *Traversal) Inline() (string, error) {
+	r := &renderer{inline: true}
+	return r.traversal(t)
+}
+
+// renderer renders traversals, binding or inlining their strings
+type renderer struct {
+	inline   bool
+	bindings map[string]string // bindings maps binding names to their strings
+	names    map[string]string // names maps the bound strings to their binding names
+}
+
+func (r *renderer) traversal(t *Traversal) (string, error) {
+	var b strings.Builder
+	b.WriteString(t.source)
+	for _, s := range append(append([]Step(nil), t.sources...), t.steps...) {
+		args, err := r.list(s.Args)
+		if err != nil {
+			return "", errors.Wrapf(err, "step %s", s.Name)
+		}
+		b.WriteString("." + s.Name + "(" + args + ")")
+	}
+	return b.String(), nil
+}
+
+func (r *renderer) list(values []interface{}) (string, error) {
+	rendered := make([]string, len(values))
+	for i, v := range values {
+		s, err := r.value(v)
+		if err != nil {
+			return "", err
+		}
+		rendered[i] = s
+	}
+	return strings.Join(rendered, ", "), nil
+}
+
+func (r *renderer) value(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		if r.inline {
+			return quote(v), nil
+		}
+		return r.bind(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int8:
+		return strconv.Itoa(int(v)), nil
+	case int16:
+		return strconv.Itoa(int(v)), nil
+	case int32:
+		return strconv.Itoa(int(v)), nil
+	case int64:
+		return strconv.FormatInt(v, 10) + "L", nil
+	case float32:
+		return floatLiteral(float64(v), 32, "Float", "f"), nil
+	case float64:
+		return floatLiteral(v, 64, "Double", "d"), nil
+	case Token:
+		if v.enum == "Cardinality" { // Only imported statically
+			return v.name, nil
+		}
+		return v.enum + "." + v.name, nil
+	case Predicate:
+		args, err := r.list(v.args)
+		if err != nil {
+			return "", err
+		}
+		return v.class + "." + v.operator + "(" + args + ")", nil
+	case *Traversal:
+		return r.traversal(v)
+	case time.Time:
+		return "new Date(" + strconv.FormatInt(v.UnixNano()/int64(time.Millisecond), 10) + "L)", nil
+	case fmt.Stringer: // Before the slices, a UUID is an array of bytes
+		return r.value(v.String())
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		list, err := r.list(items)
+		return "[" + list + "]", err
+	}
+	return "", errors.Errorf("cannot render %T in a script", v)
+}
+
+// bind returns the name of the binding of the string
+func (r *renderer) bind(s string) string {
+	if name, ok := r.names[s]; ok {
+		return name
+	}
+	name := "_" + strconv.Itoa(len(r.bindings))
+	r.bindings[name] = s
+	r.names[s] = name
+	return name
+}
+
+// floatLiteral renders a floating point number with its type suffix, or the constant of its class if not finite
+func floatLiteral(f float64, bitSize int, class, suffix string) string {
+	switch {
+	case math.IsNaN(f):
+		return class + ".NaN"
+	case math.IsInf(f, 1):
+		return class + ".POSITIVE_INFINITY"
+	case math.IsInf(f, -1):
+		return class + ".NEGATIVE_INFINITY"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize) + suffix
+}
+
+// quote returns the string as a single quoted Groovy string, which does not interpolate
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, c := range s {
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				b.WriteString(`\u00` + strconv.FormatInt(int64(c)>>4, 16) + strconv.FormatInt(int64(c)&0xf, 16))
+				continue
+			}
+			b.WriteRune(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package traversal
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestScript(t *testing.T) {
+	script, bindings, err := G.V().HasLabel("person").Has("name", "marko").Out("knows").Has("age", Gt(29)).Values("name").Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "g.V().hasLabel(_0).has(_1, _2).out(_3).has(_4, P.gt(29)).values(_1)"
+	if script != expected {
+		t.Errorf("Expected %s, got %s", expected, script)
+	}
+	expectedBindings := map[string]string{"_0": "person", "_1": "name", "_2": "marko", "_3": "knows", "_4": "age"}
+	if !reflect.DeepEqual(bindings, expectedBindings) {
+		t.Errorf("Expected bindings %v, got %v", expectedBindings, bindings)
+	}
+}
+
+func TestInline(t *testing.T) {
+	cases := []struct {
+		traversal *Traversal
+		expected  string
+	}{
+		{G.V().Has("name", "o'brien\\\n"), `g.V().has('name', 'o\'brien\\\n')`},
+		{G.AddV("person").Property(Cardinality.Single, "age", int64(29)).Property("weight", 0.5).Property("ok", true),
+			"g.addV('person').property(single, 'age', 29L).property('weight', 0.5d).property('ok', true)"},
+		{G.V().Order().By("age", Order.Desc).Range(0, 10), "g.V().order().by('age', Order.desc).range(0L, 10L)"},
+		{G.V().Has("person", "name", Within("a", "b")).ValueMap(true).By(Anon().Unfold()),
+			"g.V().has('person', 'name', P.within('a', 'b')).valueMap(true).by(__.unfold())"},
+		{G.V().As("a").Out("created").Where(Anon().In("created").Count().Is(Gte(2))).Select("a").By(T.ID),
+			"g.V().as('a').out('created').where(__.in('created').count().is(P.gte(2))).select('a').by(T.id)"},
+		{G.V().Has("name", StartingWith("ma")).Group().By(T.Label).By(Anon().Count()).Unfold(),
+			"g.V().has('name', TextP.startingWith('ma')).group().by(T.label).by(__.count()).unfold()"},
+		{G.V(1).Coalesce(Anon().OutE("knows").Where(Anon().InV().HasID(2)), Anon().AddE("knows").To(Anon().V(2))),
+			"g.V(1).coalesce(__.outE('knows').where(__.inV().hasId(2)), __.addE('knows').to(__.V(2)))"},
+		{G.V().Fold().Count(Scope.Local).Select(Column.Values), "g.V().fold().count(Scope.local).select(Column.values)"},
+		{G.V().Has("x", float32(math.Inf(1))).Has("y", nil).Step("hasId", []int{1, 2}).Drop(),
+			"g.V().has('x', Float.POSITIVE_INFINITY).has('y', null).hasId([1, 2]).drop()"},
+		{G.E().Limit(1).Path(), "g.E().limit(1L).path()"},
+		{NewSource("social").With("Neptune#repeatMode", "BFS").V().Count(), "social.with('Neptune#repeatMode', 'BFS').V().count()"},
+	}
+	for _, tc := range cases {
+		script, err := tc.traversal.Inline()
+		if err != nil {
+			t.Errorf("Unexpected error rendering %s: %v", tc.expected, err)
+			continue
+		}
+		if script != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, script)
+		}
+	}
+}
+
+// TestScriptAnonymousBindings tests that anonymous traversals share the bindings of the traversal they are in
+func TestScriptAnonymousBindings(t *testing.T) {
+	script, bindings, err := G.V().Coalesce(Anon().Has("name", "marko"), Anon().AddV("person").Property("name", "marko")).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "g.V().coalesce(__.has(_0, _1), __.addV(_2).property(_0, _1))"; script != expected {
+		t.Errorf("Expected %s, got %s", expected, script)
+	}
+	if len(bindings) != 3 {
+		t.Errorf("Expected 3 bindings, got %v", bindings)
+	}
+}
+
+// TestScriptUUIDAndTime tests that UUIDs are bound as strings and times rendered as dates of their epoch millis
+func TestScriptUUIDAndTime(t *testing.T) {
+	id := uuid.Must(uuid.FromString("0b6a4d1e-8d55-4b9c-9e4f-6b0e5a1c2d3f"))
+	joined := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	script, bindings, err := G.V(id).Has("joined", Gt(joined)).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "g.V(_0).has(_1, P.gt(new Date(1577930645000L)))"; script != expected {
+		t.Errorf("Expected %s, got %s", expected, script)
+	}
+	expectedBindings := map[string]string{"_0": id.String(), "_1": "joined"}
+	if !reflect.DeepEqual(bindings, expectedBindings) {
+		t.Errorf("Expected bindings %v, got %v", expectedBindings, bindings)
+	}
+
+	inline, err := G.V(id).Has("joined", joined).Inline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "g.V('0b6a4d1e-8d55-4b9c-9e4f-6b0e5a1c2d3f').has('joined', new Date(1577930645000L))"; inline != expected {
+		t.Errorf("Expected %s, got %s", expected, inline)
+	}
+}
+
+func TestScriptUnsupportedValue(t *testing.T) {
+	if _, _, err := G.V().Has("name", struct{}{}).Script(); err == nil {
+		t.Error("Expected an error rendering a struct")
+	}
+}
@@ -0,0 +1,136 @@
+package traversal
+
+// Token is a value of a Gremlin enum, such as T.id or Order.desc.
+type Token struct {
+	enum string
+	name string
+}
+
+// Enum returns the name of the enum of the token: T, Order, Cardinality, Scope or Column.
+func (t Token) Enum() string {
+	return t.enum
+}
+
+// Name returns the name of the token within its enum.
+func (t Token) Name() string {
+	return t.name
+}
+
+// T holds the tokens of the T enum, the parts of an element.
+var T = struct {
+	ID, Label, Key, Value Token
+}{
+	ID:    Token{"T", "id"},
+	Label: Token{"T", "label"},
+	Key:   Token{"T", "key"},
+	Value: Token{"T", "value"},
+}
+
+// Order holds the tokens of the Order enum, the directions of By steps modulating Order.
+var Order = struct {
+	Asc, Desc, Shuffle Token
+}{
+	Asc:     Token{"Order", "asc"},
+	Desc:    Token{"Order", "desc"},
+	Shuffle: Token{"Order", "shuffle"},
+}
+
+// Cardinality holds the tokens of the Cardinality enum, how Property adds a value to a vertex.
+var Cardinality = struct {
+	Single, List, Set Token
+}{
+	Single: Token{"Cardinality", "single"},
+	List:   Token{"Cardinality", "list"},
+	Set:    Token{"Cardinality", "set"},
+}
+
+// Scope holds the tokens of the Scope enum, whether steps such as Order and Count work across objects or within.
+var Scope = struct {
+	Global, Local Token
+}{
+	Global: Token{"Scope", "global"},
+	Local:  Token{"Scope", "local"},
+}
+
+// Column holds the tokens of the Column enum, the keys or values of a map for Select.
+var Column = struct {
+	Keys, Values Token
+}{
+	Keys:   Token{"Column", "keys"},
+	Values: Token{"Column", "values"},
+}
+
+// Predicate is a comparison for steps such as Has, Is and Where, a P or TextP of Gremlin.
+type Predicate struct {
+	class    string
+	operator string
+	args     []interface{}
+}
+
+// Class returns P or TextP.
+func (p Predicate) Class() string {
+	return p.class
+}
+
+// Operator returns the name of the comparison, such as gt or within.
+func (p Predicate) Operator() string {
+	return p.operator
+}
+
+// Args returns the values compared against.
+func (p Predicate) Args() []interface{} {
+	return p.args
+}
+
+func predicate(operator string, args ...interface{}) Predicate {
+	return Predicate{class: "P", operator: operator, args: args}
+}
+
+func textPredicate(operator string, value string) Predicate {
+	return Predicate{class: "TextP", operator: operator, args: []interface{}{value}}
+}
+
+// Eq matches values equal to the value.
+func Eq(value interface{}) Predicate { return predicate("eq", value) }
+
+// Neq matches values not equal to the value.
+func Neq(value interface{}) Predicate { return predicate("neq", value) }
+
+// Lt matches values less than the value.
+func Lt(value interface{}) Predicate { return predicate("lt", value) }
+
+// Lte matches values less than or equal to the value.
+func Lte(value interface{}) Predicate { return predicate("lte", value) }
+
+// Gt matches values greater than the value.
+func Gt(value interface{}) Predicate { return predicate("gt", value) }
+
+// Gte matches values greater than or equal to the value.
+func Gte(value interface{}) Predicate { return predicate("gte", value) }
+
+// Inside matches values between low and high, both excluded.
+func Inside(low, high interface{}) Predicate { return predicate("inside", low, high) }
+
+// Outside matches values below low or above high.
+func Outside(low, high interface{}) Predicate { return predicate("outside", low, high) }
+
+// Between matches values from low, included, to high, excluded.
+func Between(low, high interface{}) Predicate { return predicate("between", low, high) }
+
+// Within matches values equal to one of the values.
+func Within(values ...interface{}) Predicate { return predicate("within", values...) }
+
+// Without matches values equal to none of the values.
+func Without(values ...interface{}) Predicate { return predicate("without", values...) }
+
+// Containing matches strings containing the value.
+func Containing(value string) Predicate { return textPredicate("containing", value) }
+
+// NotContaining matches strings not containing the value.
+func NotContaining(value string) Predicate { return textPredicate("notContaining", value) }
+
+// StartingWith matches strings starting with the value.
+func StartingWith(value string) Predicate { return textPredicate("startingWith", value) }
+
+// EndingWith matches strings ending with the value.
+func EndingWith(value string) Predicate { return textPredicate("endingWith", value) }
@@ -0,0 +1,302 @@
+// Package traversal builds Gremlin traversals with a fluent API instead of formatting scripts by hand. A traversal
+// renders to a Gremlin-Groovy script with its string values in bindings, for Client.ExecuteWithBindings, or with
+// every value inlined as an escaped literal for servers that do not support bindings such as Neptune.
+//
+//	script, bindings, err := traversal.G.V().HasLabel("person").Has("name", name).Out("knows").Values("age").Script()
+package traversal
+
+// Step is a step of a traversal and its arguments.
+type Step struct {
+	Name string
+	Args []interface{}
+}
+
+// Traversal is a traversal being built. Its methods append a step and return the traversal, so they change it in
+// place: start a new traversal from the source for every query.
+type Traversal struct {
+	source  string
+	sources []Step
+	steps   []Step
+}
+
+// Source spawns traversals. G is the usual graph traversal source.
+type Source struct {
+	name  string
+	steps []Step
+}
+
+// G is the traversal source bound to g on Gremlin Server.
+var G = NewSource("g")
+
+// NewSource returns the traversal source bound to name on Gremlin Server.
+func NewSource(name string) Source {
+	return Source{name: name}
+}
+
+// Anon starts an anonymous traversal, the __ of Gremlin, to pass to steps such as Where, Coalesce and By.
+func Anon() *Traversal {
+	return &Traversal{source: "__"}
+}
+
+// With returns the source with the configuration option, such as the query hints of Neptune.
+func (s Source) With(key string, value ...interface{}) Source {
+	return s.configure("with", append([]interface{}{key}, value...))
+}
+
+// WithSideEffect returns the source with the side effect available to its traversals under the key.
+func (s Source) WithSideEffect(key string, value interface{}) Source {
+	return s.configure("withSideEffect", []interface{}{key, value})
+}
+
+func (s Source) configure(name string, args []interface{}) Source {
+	steps := make([]Step, len(s.steps), len(s.steps)+1) // Copied so that sources configured from s do not share steps
+	copy(steps, s.steps)
+	return Source{name: s.name, steps: append(steps, Step{Name: name, Args: args})}
+}
+
+func (s Source) spawn(name string, args []interface{}) *Traversal {
+	t := &Traversal{source: s.name, sources: s.steps}
+	return t.Step(name, args...)
+}
+
+// V starts a traversal over the vertices with the ids, or all of them.
+func (s Source) V(ids ...interface{}) *Traversal {
+	return s.spawn("V", ids)
+}
+
+// E starts a traversal over the edges with the ids, or all of them.
+func (s Source) E(ids ...interface{}) *Traversal {
+	return s.spawn("E", ids)
+}
+
+// AddV starts a traversal adding a vertex, with the label or the traversal giving it if any.
+func (s Source) AddV(label ...interface{}) *Traversal {
+	return s.spawn("addV", label)
+}
+
+// AddE starts a traversal adding an edge with the label or the traversal giving it.
+func (s Source) AddE(label interface{}) *Traversal {
+	return s.spawn("addE", []interface{}{label})
+}
+
+// Inject starts a traversal over the values.
+func (s Source) Inject(values ...interface{}) *Traversal {
+	return s.spawn("inject", values)
+}
+
+// Source returns the name of the source the traversal was spawned from, __ for anonymous traversals.
+func (t *Traversal) Source() string {
+	return t.source
+}
+
+// Sources returns the configuration of the source of the traversal, as steps.
+func (t *Traversal) Sources() []Step {
+	return t.sources
+}
+
+// Steps returns the steps of the traversal.
+func (t *Traversal) Steps() []Step {
+	return t.steps
+}
+
+// mutations are the steps changing the graph
+var mutations = map[string]bool{
+	"addV":     true,
+	"addE":     true,
+	"property": true,
+	"drop":     true,
+	"mergeV":   true,
+	"mergeE":   true,
+	"io":       true,
+}
+
+// ReadOnly reports whether the traversal leaves the graph as it is, none of its steps and of the steps of the
+// anonymous traversals passed to them adding, changing or dropping elements.
+func (t *Traversal) ReadOnly() bool {
+	for _, step := range t.steps {
+		if mutations[step.Name] {
+			return false
+		}
+		for _, arg := range step.Args {
+			if nested, ok := arg.(*Traversal); ok && !nested.ReadOnly() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Step appends a step by name, for the steps without a method of their own.
+func (t *Traversal) Step(name string, args ...interface{}) *Traversal {
+	t.steps = append(t.steps, Step{Name: name, Args: args})
+	return t
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+func traversalArgs(values []*Traversal) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// V appends a step to the vertices with the ids, or all of them.
+func (t *Traversal) V(ids ...interface{}) *Traversal { return t.Step("V", ids...) }
+
+// AddV appends a step adding a vertex, with the label or the traversal giving it if any.
+func (t *Traversal) AddV(label ...interface{}) *Traversal { return t.Step("addV", label...) }
+
+// AddE appends a step adding an edge with the label or the traversal giving it.
+func (t *Traversal) AddE(label interface{}) *Traversal { return t.Step("addE", label) }
+
+// From sets the outgoing vertex of an added edge, a step label or a traversal.
+func (t *Traversal) From(vertex interface{}) *Traversal { return t.Step("from", vertex) }
+
+// To sets the incoming vertex of an added edge, a step label or a traversal.
+func (t *Traversal) To(vertex interface{}) *Traversal { return t.Step("to", vertex) }
+
+// Property sets a property: optionally a Cardinality, then the key and the value, then any meta-properties.
+func (t *Traversal) Property(args ...interface{}) *Traversal { return t.Step("property", args...) }
+
+// Has filters on a property: the key, or the key and a value or Predicate, or a label, the key and a value.
+func (t *Traversal) Has(args ...interface{}) *Traversal { return t.Step("has", args...) }
+
+// HasLabel filters on the label.
+func (t *Traversal) HasLabel(labels ...interface{}) *Traversal { return t.Step("hasLabel", labels...) }
+
+// HasID filters on the id.
+func (t *Traversal) HasID(ids ...interface{}) *Traversal { return t.Step("hasId", ids...) }
+
+// HasKey filters properties on their key.
+func (t *Traversal) HasKey(keys ...interface{}) *Traversal { return t.Step("hasKey", keys...) }
+
+// HasNot filters out the elements with the property.
+func (t *Traversal) HasNot(key string) *Traversal { return t.Step("hasNot", key) }
+
+// Out moves to the adjacent vertices over outgoing edges with the labels.
+func (t *Traversal) Out(labels ...string) *Traversal { return t.Step("out", stringArgs(labels)...) }
+
+// In moves to the adjacent vertices over incoming edges with the labels.
+func (t *Traversal) In(labels ...string) *Traversal { return t.Step("in", stringArgs(labels)...) }
+
+// Both moves to the adjacent vertices over edges with the labels.
+func (t *Traversal) Both(labels ...string) *Traversal { return t.Step("both", stringArgs(labels)...) }
+
+// OutE moves to the outgoing edges with the labels.
+func (t *Traversal) OutE(labels ...string) *Traversal { return t.Step("outE", stringArgs(labels)...) }
+
+// InE moves to the incoming edges with the labels.
+func (t *Traversal) InE(labels ...string) *Traversal { return t.Step("inE", stringArgs(labels)...) }
+
+// BothE moves to the edges with the labels.
+func (t *Traversal) BothE(labels ...string) *Traversal { return t.Step("bothE", stringArgs(labels)...) }
+
+// OutV moves to the outgoing vertex of the edges.
+func (t *Traversal) OutV() *Traversal { return t.Step("outV") }
+
+// InV moves to the incoming vertex of the edges.
+func (t *Traversal) InV() *Traversal { return t.Step("inV") }
+
+// BothV moves to both vertices of the edges.
+func (t *Traversal) BothV() *Traversal { return t.Step("bothV") }
+
+// OtherV moves to the vertex of the edges that was not come from.
+func (t *Traversal) OtherV() *Traversal { return t.Step("otherV") }
+
+// As labels the step for Select and Where.
+func (t *Traversal) As(labels ...string) *Traversal { return t.Step("as", stringArgs(labels)...) }
+
+// Select selects labelled steps, the keys of a map or a Column.
+func (t *Traversal) Select(args ...interface{}) *Traversal { return t.Step("select", args...) }
+
+// Where filters on a traversal, or on a labelled step and a Predicate.
+func (t *Traversal) Where(args ...interface{}) *Traversal { return t.Step("where", args...) }
+
+// Not filters out what the traversal matches.
+func (t *Traversal) Not(traversal *Traversal) *Traversal { return t.Step("not", traversal) }
+
+// Is filters on the value or Predicate.
+func (t *Traversal) Is(value interface{}) *Traversal { return t.Step("is", value) }
+
+// Order sorts, optionally in a Scope, by the following By steps.
+func (t *Traversal) Order(scope ...interface{}) *Traversal { return t.Step("order", scope...) }
+
+// By modulates the previous step with a key, a traversal or a Token, optionally followed by an Order.
+func (t *Traversal) By(args ...interface{}) *Traversal { return t.Step("by", args...) }
+
+// Range keeps the objects from low, included, to high, excluded.
+func (t *Traversal) Range(low, high int64) *Traversal { return t.Step("range", low, high) }
+
+// Limit keeps the first n objects.
+func (t *Traversal) Limit(n int64) *Traversal { return t.Step("limit", n) }
+
+// Skip drops the first n objects.
+func (t *Traversal) Skip(n int64) *Traversal { return t.Step("skip", n) }
+
+// Dedup drops duplicates.
+func (t *Traversal) Dedup(labels ...string) *Traversal { return t.Step("dedup", stringArgs(labels)...) }
+
+// Count counts the objects.
+func (t *Traversal) Count(scope ...interface{}) *Traversal { return t.Step("count", scope...) }
+
+// Group groups the objects by the following By steps, the key first then the value.
+func (t *Traversal) Group() *Traversal { return t.Step("group") }
+
+// GroupCount counts the objects by the following By step.
+func (t *Traversal) GroupCount() *Traversal { return t.Step("groupCount") }
+
+// Fold gathers the objects into a list.
+func (t *Traversal) Fold() *Traversal { return t.Step("fold") }
+
+// Unfold spreads lists and maps into their items.
+func (t *Traversal) Unfold() *Traversal { return t.Step("unfold") }
+
+// Coalesce returns the result of the first traversal that has one.
+func (t *Traversal) Coalesce(traversals ...*Traversal) *Traversal {
+	return t.Step("coalesce", traversalArgs(traversals)...)
+}
+
+// Union merges the results of the traversals.
+func (t *Traversal) Union(traversals ...*Traversal) *Traversal {
+	return t.Step("union", traversalArgs(traversals)...)
+}
+
+// Optional returns the result of the traversal, or the object when it has none.
+func (t *Traversal) Optional(traversal *Traversal) *Traversal { return t.Step("optional", traversal) }
+
+// Drop removes the elements and properties.
+func (t *Traversal) Drop() *Traversal { return t.Step("drop") }
+
+// Values moves to the values of the properties with the keys.
+func (t *Traversal) Values(keys ...string) *Traversal { return t.Step("values", stringArgs(keys)...) }
+
+// Properties moves to the properties with the keys.
+func (t *Traversal) Properties(keys ...string) *Traversal {
+	return t.Step("properties", stringArgs(keys)...)
+}
+
+// ValueMap maps the elements to their properties, optionally with their id and label when given true.
+func (t *Traversal) ValueMap(args ...interface{}) *Traversal { return t.Step("valueMap", args...) }
+
+// Project maps the objects to maps with the keys, valued by the following By steps.
+func (t *Traversal) Project(keys ...string) *Traversal { return t.Step("project", stringArgs(keys)...) }
+
+// ID maps the elements to their id.
+func (t *Traversal) ID() *Traversal { return t.Step("id") }
+
+// Label maps the elements to their label.
+func (t *Traversal) Label() *Traversal { return t.Step("label") }
+
+// Constant maps the objects to the value.
+func (t *Traversal) Constant(value interface{}) *Traversal { return t.Step("constant", value) }
+
+// Path maps the objects to the path that led to them.
+func (t *Traversal) Path() *Traversal { return t.Step("path") }
@@ -0,0 +1,24 @@
+package traversal
+
+import "testing"
+
+func TestReadOnly(t *testing.T) {
+	cases := []struct {
+		name      string
+		traversal *Traversal
+		readOnly  bool
+	}{
+		{"values", G.V().HasLabel("person").Out("knows").Values("name"), true},
+		{"nested read", G.V().Where(Anon().Out("knows")).Coalesce(Anon().Values("name"), Anon().Constant("none")), true},
+		{"addV", G.AddV("person"), false},
+		{"property", G.V("1").Property("name", "marko"), false},
+		{"drop", G.V().HasLabel("person").Drop(), false},
+		{"upsert", G.V().Has("name", "marko").Fold().Coalesce(Anon().Unfold(), Anon().AddV("person")), false},
+		{"mergeV", G.Inject(1).Step("mergeV"), false},
+	}
+	for _, c := range cases {
+		if readOnly := c.traversal.ReadOnly(); readOnly != c.readOnly {
+			t.Errorf("Expected %s to be read-only %v, got %v", c.name, c.readOnly, readOnly)
+		}
+	}
+}